@@ -2,7 +2,10 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/sencydai/gameworld/proto/encrypt"
@@ -17,6 +20,9 @@ const (
 )
 
 type Account struct {
+	index      int
+	target     *Target
+	netProfile *NetProfile
 	conn       *websocket.Conn
 	connStatus connectStatus
 	encrypt    *encrypt.Encrypt
@@ -25,23 +31,79 @@ type Account struct {
 	lock       sync.RWMutex
 
 	accountName string
+	password    string
+	platform    string
 	accountId   int
 	actorId     int64
+	//日志标识,其他goroutine打印日志时读取
+	logTag atomic.Value
+
+	//主动下线后的离线时长
+	offlineDelay time.Duration
+
+	//请求发送时间,用于统计延迟
+	pending map[int][]time.Time
+
+	//账号下的角色列表
+	actors []*ActorInfo
+
+	//创建角色参数
+	createName     string
+	createSex      int
+	createJob      int
+	createCamp     int
+	createAttempts int
+
+	//等待服务器推送随机名称,及提前收到的随机名称
+	waitRandomName bool
+	randomName     string
+	randomSex      int
+
+	//行为场景及序列进度
+	scenario *Scenario
+	seqStep  int
+	seqCount int
+
+	//等待中的应答期望
+	expects   []*expectation
+	expectSeq int
+
+	//消息和定时器回调串行执行
+	mailbox chan func()
+	done    chan struct{}
 
 	data map[string]interface{}
 }
 
 func (account *Account) Close() {
 	account.lock.Lock()
-	defer account.lock.Unlock()
-
 	if account.closed {
+		account.lock.Unlock()
 		return
 	}
 	account.closed = true
+	account.connStatus = statusDisconnect
 	account.conn.Close()
+	account.pending = nil
+	if account.done != nil {
+		close(account.done)
+	}
+	account.lock.Unlock()
 
 	StopAccountTimers(account)
+	removeAccount(account)
+}
+
+// accountId/actorId只在账号goroutine中修改,修改后调用
+func (account *Account) updateLogTag() {
+	account.logTag.Store(fmt.Sprintf("%s,%d,%d", account.accountName, account.accountId, account.actorId))
+}
+
+func (account *Account) LogTag() string {
+	if tag, ok := account.logTag.Load().(string); ok {
+		return tag
+	}
+	return account.accountName + ",0,0"
 }
 
 func (account *Account) IsClose() bool {
@@ -51,8 +113,44 @@ func (account *Account) IsClose() bool {
 	return account.closed
 }
 
+func (account *Account) Status() connectStatus {
+	account.lock.RLock()
+	defer account.lock.RUnlock()
+
+	return account.connStatus
+}
+
+func (account *Account) setStatus(status connectStatus) {
+	account.lock.Lock()
+	defer account.lock.Unlock()
+
+	account.connStatus = status
+}
+
+func (account *Account) setOfflineDelay(delay time.Duration) {
+	account.lock.Lock()
+	defer account.lock.Unlock()
+
+	account.offlineDelay = delay
+}
+
+func (account *Account) OfflineDelay() time.Duration {
+	account.lock.RLock()
+	defer account.lock.RUnlock()
+
+	return account.offlineDelay
+}
+
+func (account *Account) writeRaw(data []byte) {
+	account.lock.Lock()
+	defer account.lock.Unlock()
+
+	account.setWriteDeadline()
+	account.conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
 func (account *Account) onConnect() {
-	account.conn.WriteMessage(websocket.BinaryMessage, pack.GetBytes(account.encrypt.GetSelfSalt()))
+	account.writeRaw(pack.GetBytes(account.encrypt.GetSelfSalt()))
 }
 
 func (account *Account) setTargetSalt(data []byte) {
@@ -60,15 +158,32 @@ func (account *Account) setTargetSalt(data []byte) {
 	var value uint32
 	pack.Read(reader, &value)
 	account.encrypt.SetTargetSalt(value)
+	if churnHandshakeDrop(account) {
+		return
+	}
 
-	account.conn.WriteMessage(websocket.BinaryMessage, pack.GetBytes(account.encrypt.GetCheckKey()))
-	account.connStatus = statusCommunication
+	account.writeRaw(pack.GetBytes(account.encrypt.GetCheckKey()))
+	account.setStatus(statusCommunication)
 
-	account.send(proto.System, proto.SystemCLogin, gConfigs.ServerId, account.accountName, "e10adc3949ba59abbe56e057f20f883e")
+	account.send(proto.System, proto.SystemCLogin, account.target.ServerId, account.accountName, account.password)
 }
 
 var headerData = pack.GetBytes(pack.DEFAULT_TAG, 0, int16(0), pack.DEFAULT_CRC_KEY)
 
+// 填充包长和校验码并加密校验字段,data以headerData开头
+func sealFrame(enc *encrypt.Encrypt, data []byte) {
+	Len := pack.GetBytes(len(data) - pack.HEAD_SIZE)
+	copy(data[4:], Len)
+
+	msgCK := pack.GetBytes(enc.GetCRC16ByPos(data, pack.HEAD_SIZE, 0))
+	copy(data[8:], msgCK)
+
+	headerCRC := pack.GetBytes(enc.GetCRC16(data, pack.HEAD_SIZE))
+	copy(data[10:], headerCRC)
+
+	enc.Encode(data, 8, 4)
+}
+
 func (account *Account) send(sysId, cmdId byte, datas ...interface{}) {
 	account.lock.Lock()
 	defer account.lock.Unlock()
@@ -81,18 +196,15 @@ func (account *Account) send(sysId, cmdId byte, datas ...interface{}) {
 	pack.Write(writer, datas...)
 
 	data := writer.Bytes()
-	Len := pack.GetBytes(len(data) - pack.HEAD_SIZE)
-	copy(data[4:], Len)
-
-	msgCK := pack.GetBytes(account.encrypt.GetCRC16ByPos(data, pack.HEAD_SIZE, 0))
-	copy(data[8:], msgCK)
-
-	headerCRC := pack.GetBytes(account.encrypt.GetCRC16(data, pack.HEAD_SIZE))
-	copy(data[10:], headerCRC)
+	//包头后为pid(4)+sysId+cmdId
+	capture.Record(account, dirOut, account.pid, sysId, cmdId, data[pack.HEAD_SIZE+6:])
 
-	account.encrypt.Encode(data, 8, 4)
+	sealFrame(account.encrypt, data)
 
+	account.setWriteDeadline()
 	if account.conn.WriteMessage(websocket.BinaryMessage, data) == nil {
 		//log.Printf(account, "send %d %d", sysId, cmdId)
+		countSent(sysId, cmdId)
+		latencyOnSend(account, sysId, cmdId)
 	}
 }
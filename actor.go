@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/sencydai/gameworld/base"
+)
+
+const (
+	actorPolicyFirst  = "first"
+	actorPolicyRandom = "random"
+	actorPolicyNewest = "newest"
+	actorPolicyCreate = "create"
+)
+
+type ActorInfo struct {
+	actorId int64
+	name    string
+	head    int
+	sex     int
+	level   int
+	job     int
+	camp    int
+}
+
+// 按策略选择登陆角色,返回nil表示需要创建新角色
+func chooseActor(account *Account) *ActorInfo {
+	actors := account.actors
+	if len(actors) == 0 {
+		return nil
+	}
+
+	switch gConfigs.ActorPolicy {
+	case actorPolicyRandom:
+		return actors[base.Rand(0, len(actors)-1)]
+	case actorPolicyNewest:
+		return newestActor(actors)
+	case actorPolicyCreate:
+		if len(actors) < gConfigs.MaxActors {
+			return nil
+		}
+		return actors[base.Rand(0, len(actors)-1)]
+	}
+	return actors[0]
+}
+
+func newestActor(actors []*ActorInfo) *ActorInfo {
+	newest := actors[0]
+	for _, actor := range actors[1:] {
+		if actor.actorId > newest.actorId {
+			newest = actor
+		}
+	}
+	return newest
+}
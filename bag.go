@@ -1,45 +1,25 @@
 package main
 
 import (
-	"bytes"
+	"fmt"
 
 	"github.com/sencydai/gameworld/base"
 	"github.com/sencydai/gameworld/proto/pack"
 	proto "github.com/sencydai/gameworld/proto/protocol"
 )
 
+const (
+	//奖励来源
+	awardSourceFight   = 1
+	awardSourceOpenBox = 2
+)
+
+// 英雄、装备、神器结构由msg.schema生成
 type BagData struct {
 	items  map[int]int
-	heros  map[int]*BagHeroData
-	equips map[int]*BagEquipData
-	artis  map[int]*BagArtiData
-}
-
-type BagHeroData struct {
-	guid    int
-	posType byte
-	pos     int16
-	posMap  int
-	id      int
-	level   int16
-	exp     int
-	stage   int16
-}
-
-type BagEquipData struct {
-	guid  int
-	pos   int
-	id    int
-	level int
-}
-
-type BagArtiData struct {
-	guid        int
-	pos         int
-	id          int
-	attrs       []int
-	strengLevel []int
-	strengPos   int
+	heros  map[int]*BagHero
+	equips map[int]*BagEquip
+	artis  map[int]*BagArti
 }
 
 func GetBagData(account *Account) *BagData {
@@ -59,53 +39,78 @@ func GetBagItems(account *Account) map[int]int {
 	return bagData.items
 }
 
-func GetBagHeros(account *Account) map[int]*BagHeroData {
+func GetBagHeros(account *Account) map[int]*BagHero {
 	bagData := GetBagData(account)
 	if bagData.heros == nil {
-		bagData.heros = make(map[int]*BagHeroData)
+		bagData.heros = make(map[int]*BagHero)
 	}
 	return bagData.heros
 }
 
-func GetBagEquips(account *Account) map[int]*BagEquipData {
+func GetBagEquips(account *Account) map[int]*BagEquip {
 	bagData := GetBagData(account)
 	if bagData.equips == nil {
-		bagData.equips = make(map[int]*BagEquipData)
+		bagData.equips = make(map[int]*BagEquip)
 	}
 	return bagData.equips
 }
 
-func GetBagArtis(account *Account) map[int]*BagArtiData {
+func GetBagArtis(account *Account) map[int]*BagArti {
 	bagData := GetBagData(account)
 	if bagData.artis == nil {
-		bagData.artis = make(map[int]*BagArtiData)
+		bagData.artis = make(map[int]*BagArti)
 	}
 	return bagData.artis
 }
 
+// guid -> 模板id
+func heroIds(account *Account) map[int]int {
+	ids := make(map[int]int)
+	for guid, hero := range GetBagHeros(account) {
+		ids[guid] = hero.Id
+	}
+	return ids
+}
+
+func equipIds(account *Account) map[int]int {
+	ids := make(map[int]int)
+	for guid, equip := range GetBagEquips(account) {
+		ids[guid] = equip.Id
+	}
+	return ids
+}
+
+func artiIds(account *Account) map[int]int {
+	ids := make(map[int]int)
+	for guid, arti := range GetBagArtis(account) {
+		ids[guid] = arti.Id
+	}
+	return ids
+}
+
 func init() {
 	//物品初始化
 	RegServerHandle(proto.Bag, proto.BagSItemInit, HandleBagItemInit)
 	//物品删除
 	RegServerHandle(proto.Bag, proto.BagSItemDelete, HandleBagItemDelete)
 	//英雄初始化
-	RegServerHandle(proto.Bag, proto.BagSHeroInit, HandleBagHeroInit)
+	RegServerHandle(proto.Bag, proto.BagSHeroInit, BagHeroInitMsgHandler(HandleBagHeroInit))
 	//英雄修改
-	RegServerHandle(proto.Bag, proto.BagSHeroUpdate, HandleBagHeroUpdate)
+	RegServerHandle(proto.Bag, proto.BagSHeroUpdate, BagHeroUpdateMsgHandler(HandleBagHeroUpdate))
 	//英雄删除
-	RegServerHandle(proto.Bag, proto.BagSHeroDelete, HandleBagHeroDelete)
+	RegServerHandle(proto.Bag, proto.BagSHeroDelete, BagHeroDeleteMsgHandler(HandleBagHeroDelete))
 	//装备初始化
-	RegServerHandle(proto.Bag, proto.BagSEquipInit, HandleBagEquipInit)
+	RegServerHandle(proto.Bag, proto.BagSEquipInit, BagEquipInitMsgHandler(HandleBagEquipInit))
 	//装备修改
-	RegServerHandle(proto.Bag, proto.BagSEquipUpdate, HandleBagEquipUpdate)
+	RegServerHandle(proto.Bag, proto.BagSEquipUpdate, BagEquipUpdateMsgHandler(HandleBagEquipUpdate))
 	//装备删除
-	RegServerHandle(proto.Bag, proto.BagSEquipDelete, HandleBagEquipDelete)
+	RegServerHandle(proto.Bag, proto.BagSEquipDelete, BagEquipDeleteMsgHandler(HandleBagEquipDelete))
 	//神器初始化
-	RegServerHandle(proto.Bag, proto.BagSArtiInit, HandleBagArtiInit)
+	RegServerHandle(proto.Bag, proto.BagSArtiInit, BagArtiInitMsgHandler(HandleBagArtiInit))
 	//神器修改
-	RegServerHandle(proto.Bag, proto.BagSArtiUpdate, HandleBagArtiUpdate)
+	RegServerHandle(proto.Bag, proto.BagSArtiUpdate, BagArtiUpdateMsgHandler(HandleBagArtiUpdate))
 	//神器删除
-	RegServerHandle(proto.Bag, proto.BagSArtiDelete, HandleBagArtiDelete)
+	RegServerHandle(proto.Bag, proto.BagSArtiDelete, BagArtiDeleteMsgHandler(HandleBagArtiDelete))
 	//货币初始化
 	RegServerHandle(proto.Bag, proto.BagSCurrencyInit, HandleBagCurrencyInit)
 	//货币删除
@@ -119,271 +124,309 @@ func init() {
 	RegCommonMsg(sendCompose)
 }
 
-func HandleBagItemInit(account *Account, reader *bytes.Reader) {
-	items := GetBagItems(account)
-	var count int16
-	pack.Read(reader, &count)
-	for i := int16(0); i < count; i++ {
+func HandleBagItemInit(account *Account, reader *MsgReader) error {
+	added := make(map[int]int)
+	count := reader.Count()
+	for i := 0; i < count; i++ {
 		var itemType int16
-		var itemCount int16
-		pack.Read(reader, &itemType, &itemCount)
-		for j := int16(0); j < itemCount; j++ {
+		reader.Values(&itemType)
+		itemCount := reader.Count()
+		for j := 0; j < itemCount; j++ {
 			var id int
 			var total int
-			pack.Read(reader, &id, &total)
-			items[id] = total
+			reader.Values(&id, &total)
+			added[id] = total
 		}
 	}
-}
+	if err := reader.Finish(); err != nil {
+		return err
+	}
 
-func HandleBagCurrencyInit(account *Account, reader *bytes.Reader) {
 	items := GetBagItems(account)
-	var count int16
-	pack.Read(reader, &count)
-	for i := int16(0); i < count; i++ {
+	for id, total := range added {
+		items[id] = total
+	}
+	return nil
+}
+
+func HandleBagCurrencyInit(account *Account, reader *MsgReader) error {
+	added := make(map[int]int)
+	count := reader.Count()
+	for i := 0; i < count; i++ {
 		var id int
 		var total int
-		pack.Read(reader, &id, &total)
+		reader.Values(&id, &total)
+		added[id] = total
+	}
+	if err := reader.Finish(); err != nil {
+		return err
+	}
+
+	items := GetBagItems(account)
+	for id, total := range added {
 		items[id] = total
 	}
+	return nil
 }
 
-func deleteItem(account *Account, id, total, change int) {
+// 以服务器数量为准更新,与客户端数量不符时返回错误
+func deleteItem(account *Account, id, total, change int) error {
 	items := GetBagItems(account)
-	if cur, ok := items[id]; ok {
-		newCount := cur + change
-		if newCount != total || newCount < 0 || total < 0 {
-			log.Printf(account, "HandleBagItemDelete: id(%d),client(%d),server(%d),change(%d)", id, cur, total, change)
-		}
-	} else {
-		log.Printf(account, "HandleBagItemDelete: not find item,id(%d),server(%d),change(%d)",
-			id, total, change)
-	}
+	cur, ok := items[id]
 
 	items[id] = total
 	if items[id] <= 0 {
 		delete(items, id)
 	}
+
+	if !ok {
+		return fmt.Errorf("not find item,id(%d),server(%d),change(%d)", id, total, change)
+	}
+	if newCount := cur + change; newCount != total || newCount < 0 || total < 0 {
+		return fmt.Errorf("item count mismatch,id(%d),client(%d),server(%d),change(%d)", id, cur, total, change)
+	}
+	return nil
 }
 
-func HandleBagItemDelete(account *Account, reader *bytes.Reader) {
+// 删除时找不到的guid
+func notFound(kind string, guids []int) error {
+	if len(guids) == 0 {
+		return nil
+	}
+	return fmt.Errorf("not find %s%v", kind, guids)
+}
+
+func HandleBagItemDelete(account *Account, reader *MsgReader) error {
 	var itemType int
 	var id int
 	var total int
 	var change int
 
-	pack.Read(reader, &itemType, &id, &total, &change)
-	deleteItem(account, id, total, change)
+	reader.Values(&itemType, &id, &total, &change)
+	if err := reader.Finish(); err != nil {
+		return err
+	}
+	return deleteItem(account, id, total, change)
 }
 
-func HandleBagCurrencyDelete(account *Account, reader *bytes.Reader) {
+func HandleBagCurrencyDelete(account *Account, reader *MsgReader) error {
 	var id int
 	var total int
 	var change int
-	pack.Read(reader, &id, &total, &change)
-	deleteItem(account, id, total, change)
+	reader.Values(&id, &total, &change)
+	if err := reader.Finish(); err != nil {
+		return err
+	}
+	return deleteItem(account, id, total, change)
 }
 
-func HandleBagHeroInit(account *Account, reader *bytes.Reader) {
+func HandleBagHeroInit(account *Account, msg *BagHeroInitMsg) error {
 	heros := GetBagHeros(account)
-	var count int16
-	pack.Read(reader, &count)
-	for i := int16(0); i < count; i++ {
-		hero := &BagHeroData{}
-		pack.Read(reader, &hero.guid, &hero.posType, &hero.pos, &hero.posMap, &hero.id, &hero.level, &hero.exp, &hero.stage)
-		heros[hero.guid] = hero
+	for _, hero := range msg.Heros {
+		heros[hero.Guid] = hero
 	}
+	return nil
 }
 
-func HandleBagHeroUpdate(account *Account, reader *bytes.Reader) {
-	hero := &BagHeroData{}
-	pack.Read(reader, &hero.guid, &hero.posType, &hero.pos, &hero.posMap, &hero.id, &hero.level, &hero.exp, &hero.stage)
+func HandleBagHeroUpdate(account *Account, msg *BagHeroUpdateMsg) error {
+	hero := msg.Hero
 	heros := GetBagHeros(account)
-	if heros[hero.guid] == nil {
-		log.Printf(account, "HandleBagHeroUpdate: not find hero(%d)", hero.guid)
+	old := heros[hero.Guid]
+	heros[hero.Guid] = hero
+	if old == nil {
+		return fmt.Errorf("not find hero(%d)", hero.Guid)
 	}
-	heros[hero.guid] = hero
+	return nil
 }
 
-func HandleBagHeroDelete(account *Account, reader *bytes.Reader) {
+func HandleBagHeroDelete(account *Account, msg *BagHeroDeleteMsg) error {
 	heros := GetBagHeros(account)
-	var source byte
-	var count int16
-	pack.Read(reader, &source, &count)
-	for i := int16(0); i < count; i++ {
-		var guid int
-		pack.Read(reader, &guid)
+	missing := make([]int, 0)
+	for _, guid := range msg.Guids {
 		if heros[guid] == nil {
-			log.Printf(account, "HandleBagHeroDelete: not find hero(%d)", guid)
+			missing = append(missing, guid)
 		} else {
 			delete(heros, guid)
 		}
 	}
+	return notFound("heros", missing)
 }
 
-func HandleBagEquipInit(account *Account, reader *bytes.Reader) {
+func HandleBagEquipInit(account *Account, msg *BagEquipInitMsg) error {
 	equips := GetBagEquips(account)
-	var count int16
-	pack.Read(reader, &count)
-	for i := int16(0); i < count; i++ {
-		equip := &BagEquipData{}
-		pack.Read(reader, &equip.guid, &equip.pos, &equip.id, &equip.level)
-		equips[equip.guid] = equip
+	for _, equip := range msg.Equips {
+		equips[equip.Guid] = equip
 	}
+	return nil
 }
 
-func HandleBagEquipUpdate(account *Account, reader *bytes.Reader) {
-	equip := &BagEquipData{}
-	pack.Read(reader, &equip.guid, &equip.pos, &equip.id, &equip.level)
+func HandleBagEquipUpdate(account *Account, msg *BagEquipUpdateMsg) error {
+	equip := msg.Equip
 	equips := GetBagEquips(account)
-	if equips[equip.guid] == nil {
-		log.Printf(account, "HandleBagEquipUpdate: not find equip(%d)", equip.guid)
+	old := equips[equip.Guid]
+	equips[equip.Guid] = equip
+	if old == nil {
+		return fmt.Errorf("not find equip(%d)", equip.Guid)
 	}
-	equips[equip.guid] = equip
+	return nil
 }
 
-func HandleBagEquipDelete(account *Account, reader *bytes.Reader) {
+func HandleBagEquipDelete(account *Account, msg *BagEquipDeleteMsg) error {
 	equips := GetBagEquips(account)
-	var source uint8
-	var count int16
-	pack.Read(reader, &source, &count)
-	for i := int16(0); i < count; i++ {
-		var guid int
-		pack.Read(reader, &guid)
+	missing := make([]int, 0)
+	for _, guid := range msg.Guids {
 		if equips[guid] == nil {
-			log.Printf(account, "HandleBagEquipDelete: not find equip(%d)", guid)
+			missing = append(missing, guid)
 		} else {
 			delete(equips, guid)
 		}
 	}
+	return notFound("equips", missing)
 }
 
-func HandleBagArtiInit(account *Account, reader *bytes.Reader) {
+func HandleBagArtiInit(account *Account, msg *BagArtiInitMsg) error {
 	artis := GetBagArtis(account)
-	var count int16
-	pack.Read(reader, &count)
-	for i := int16(0); i < count; i++ {
-		arti := &BagArtiData{}
-		var l int16
-		pack.Read(reader, &arti.guid, &arti.pos, &arti.id, &l)
-		arti.attrs = make([]int, l)
-		for j := int16(0); j < l; j++ {
-			var value int
-			pack.Read(reader, &value)
-			arti.attrs[j] = value
-		}
-		pack.Read(reader, &l)
-		arti.strengLevel = make([]int, l)
-		for j := int16(0); j < l; j++ {
-			var value int
-			pack.Read(reader, &value)
-			arti.strengLevel[j] = value
-		}
-		pack.Read(reader, &arti.strengPos)
-		artis[arti.guid] = arti
+	for _, arti := range msg.Artis {
+		artis[arti.Guid] = arti
 	}
+	return nil
 }
 
-func HandleBagArtiUpdate(account *Account, reader *bytes.Reader) {
-	arti := &BagArtiData{}
-	var l int16
-	pack.Read(reader, &arti.guid, &arti.pos, &arti.id, &l)
-	arti.attrs = make([]int, l)
-	for j := int16(0); j < l; j++ {
-		var value int
-		pack.Read(reader, &value)
-		arti.attrs[j] = value
-	}
-	pack.Read(reader, &l)
-	arti.strengLevel = make([]int, l)
-	for j := int16(0); j < l; j++ {
-		var value int
-		pack.Read(reader, &value)
-		arti.strengLevel[j] = value
-	}
-	pack.Read(reader, &arti.strengPos)
-
+func HandleBagArtiUpdate(account *Account, msg *BagArtiUpdateMsg) error {
+	arti := msg.Arti
 	artis := GetBagArtis(account)
-	if artis[arti.guid] == nil {
-		log.Printf(account, "HandleBagArtiUpdate: not find arti(%d)", arti.guid)
+	old := artis[arti.Guid]
+	artis[arti.Guid] = arti
+	if old == nil {
+		return fmt.Errorf("not find arti(%d)", arti.Guid)
 	}
-	artis[arti.guid] = arti
+	return nil
 }
 
-func HandleBagArtiDelete(account *Account, reader *bytes.Reader) {
+func HandleBagArtiDelete(account *Account, msg *BagArtiDeleteMsg) error {
 	artis := GetBagArtis(account)
-	var count int16
-	pack.Read(reader, &count)
-	for i := int16(0); i < count; i++ {
-		var guid int
-		pack.Read(reader, &guid)
+	missing := make([]int, 0)
+	for _, guid := range msg.Guids {
 		if artis[guid] == nil {
-			log.Printf(account, "HandleBagArtiDelete: not find arti(%d)", guid)
+			missing = append(missing, guid)
 		} else {
 			delete(artis, guid)
 		}
 	}
+	return notFound("artis", missing)
+}
+
+// 解析后的单条奖励
+type bagAward struct {
+	awardType  int
+	id         int
+	addCount   int
+	totalCount int
+	guids      []int
+	artis      []*BagArti
 }
 
-func HandleBagAddAwards(account *Account, reader *bytes.Reader) {
+// 开宝箱的奖励中含有打开的物品id时认领,战斗奖励等其他来源的推送
+// 和同时打开的其他物品的奖励都不属于该请求
+func matchOpenBox(id int) ExpectMatcher {
+	return func(reader *MsgReader) (bool, error) {
+		source, awards := readAwards(reader)
+		//解析失败由处理函数统计
+		if reader.Finish() != nil || source != awardSourceOpenBox {
+			return false, nil
+		}
+		for _, award := range awards {
+			if award.awardType == 1 && award.id == id {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+func readAwards(reader *MsgReader) (int, []*bagAward) {
 	var source int
-	var l int16
-	pack.Read(reader, &source, &l)
-	for i := int16(0); i < l; i++ {
-		var awardType int
-		var id int
-		var addCount int
-		pack.Read(reader, &awardType, &id, &addCount)
-		switch awardType {
+	reader.Values(&source)
+	l := reader.Count()
+	awards := make([]*bagAward, 0, l)
+	for i := 0; i < l; i++ {
+		award := &bagAward{}
+		reader.Values(&award.awardType, &award.id, &award.addCount)
+		switch award.awardType {
+		//物品
+		case 1:
+			reader.Values(&award.totalCount)
+		//英雄 英雄装备
+		case 7, 8:
+			count := reader.CheckCount(award.addCount)
+			for j := 0; j < count; j++ {
+				var guid int
+				reader.Values(&guid)
+				award.guids = append(award.guids, guid)
+			}
+		//神器
+		case 9:
+			count := reader.CheckCount(award.addCount)
+			for j := 0; j < count; j++ {
+				arti := &BagArti{Id: award.id, StrengLevel: []int{0, 0, 0, 0}, StrengPos: 1}
+				reader.Values(&arti.Guid)
+				arti.Attrs = make([]int, reader.Count())
+				for k := range arti.Attrs {
+					reader.Values(&arti.Attrs[k])
+				}
+				award.artis = append(award.artis, arti)
+			}
+		}
+		awards = append(awards, award)
+	}
+	return source, awards
+}
+
+func HandleBagAddAwards(account *Account, reader *MsgReader) error {
+	source, awards := readAwards(reader)
+	if err := reader.Finish(); err != nil {
+		return err
+	}
+
+	//数量不符时仍以服务器为准,全部奖励处理后返回
+	var mismatch error
+	for _, award := range awards {
+		id := award.id
+		switch award.awardType {
 		//物品
 		case 1:
-			var totalCount int
-			pack.Read(reader, &totalCount)
 			items := GetBagItems(account)
 			//领主经验 公会资金
 			if id != 4 && id != 8 {
-				if oldCount, ok := items[id]; ok && (oldCount+addCount) != totalCount {
-					log.Printf(account, "HandleBagAddAwards: source(%d) item(%d) old(%d),new(%d),add(%d)",
-						source, id, oldCount, totalCount, addCount)
+				if oldCount, ok := items[id]; ok && (oldCount+award.addCount) != award.totalCount && mismatch == nil {
+					mismatch = fmt.Errorf("source(%d) item(%d) old(%d),new(%d),add(%d)",
+						source, id, oldCount, award.totalCount, award.addCount)
 				}
 
-				items[id] = totalCount
+				items[id] = award.totalCount
 			}
 
 		//英雄
 		case 7:
 			heros := GetBagHeros(account)
-			for j := 0; j < addCount; j++ {
-				var guid int
-				pack.Read(reader, &guid)
-				heros[guid] = &BagHeroData{guid: guid, id: id, level: 1}
+			for _, guid := range award.guids {
+				heros[guid] = &BagHero{Guid: guid, Id: id, Level: 1}
 			}
 		//英雄装备
 		case 8:
 			equips := GetBagEquips(account)
-			for j := 0; j < addCount; j++ {
-				var guid int
-				pack.Read(reader, &guid)
-				equips[guid] = &BagEquipData{guid: guid, id: id}
+			for _, guid := range award.guids {
+				equips[guid] = &BagEquip{Guid: guid, Id: id}
 			}
 		//神器
 		case 9:
 			artis := GetBagArtis(account)
-			for j := 0; j < addCount; j++ {
-				var guid int
-				var attrLen int16
-				pack.Read(reader, &guid, &attrLen)
-				arti := &BagArtiData{guid: guid, id: id, strengLevel: []int{0, 0, 0, 0}, strengPos: 1}
-				arti.attrs = make([]int, attrLen)
-				for k := int16(0); k < attrLen; k++ {
-					var value int
-					pack.Read(reader, &value)
-					arti.attrs[k] = value
-				}
-				artis[guid] = arti
+			for _, arti := range award.artis {
+				artis[arti.Guid] = arti
 			}
 		}
 	}
+	return mismatch
 }
 
 func sendOpenBox(account *Account) {
@@ -392,7 +435,11 @@ func sendOpenBox(account *Account) {
 		return
 	}
 	for key := range items {
-		account.send(proto.Bag, proto.BagCOpenBox, key, base.Rand(0, 10))
+		count := base.Rand(0, 10)
+		account.send(proto.Bag, proto.BagCOpenBox, key, count)
+		if count > 0 {
+			Expect(account, sendOpenBox, proto.Bag, proto.BagSAddAwards, matchOpenBox(key))
+		}
 		break
 	}
 }
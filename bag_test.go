@@ -0,0 +1,193 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/sencydai/gameworld/proto/pack"
+	proto "github.com/sencydai/gameworld/proto/protocol"
+)
+
+func TestHandleBagItems(t *testing.T) {
+	account := newTestAccount()
+	//1种类型,2个物品
+	if err := handleTestMsg(account, proto.Bag, proto.BagSItemInit, int16(1), int16(1), int16(2), 1001, 10, 1002, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := handleTestMsg(account, proto.Bag, proto.BagSCurrencyInit, int16(1), 1, 10000); err != nil {
+		t.Fatal(err)
+	}
+	items := GetBagItems(account)
+	if items[1001] != 10 || items[1002] != 5 || items[1] != 10000 {
+		t.Fatalf("items %v", items)
+	}
+
+	if err := handleTestMsg(account, proto.Bag, proto.BagSItemDelete, 1, 1001, 7, -3); err != nil {
+		t.Fatal(err)
+	}
+	if err := handleTestMsg(account, proto.Bag, proto.BagSItemDelete, 1, 1002, 0, -5); err != nil {
+		t.Fatal(err)
+	}
+	if items[1001] != 7 {
+		t.Fatalf("item 1001 %d", items[1001])
+	}
+	if _, ok := items[1002]; ok {
+		t.Fatal("item 1002 not deleted")
+	}
+
+	//数量与客户端不符,以服务器为准
+	if err := handleTestMsg(account, proto.Bag, proto.BagSItemDelete, 1, 1001, 5, -1); err == nil {
+		t.Fatal("count mismatch accepted")
+	}
+	if err := handleTestMsg(account, proto.Bag, proto.BagSCurrencyDelete, 2, 0, -1); err == nil {
+		t.Fatal("unknown currency accepted")
+	}
+	if items[1001] != 5 {
+		t.Fatalf("item 1001 %d", items[1001])
+	}
+}
+
+func TestHandleBagItemInitShortPayload(t *testing.T) {
+	account := newTestAccount()
+	//声明2个物品只带1个,背包保持不变
+	if err := handleTestMsg(account, proto.Bag, proto.BagSItemInit, int16(1), int16(1), int16(2), 1001, 10); err == nil {
+		t.Fatal("short payload accepted")
+	}
+	if items := GetBagItems(account); len(items) != 0 {
+		t.Fatalf("items %v", items)
+	}
+}
+
+func TestHandleBagHeros(t *testing.T) {
+	account := newTestAccount()
+	heros := pack.NewWriter(int16(2))
+	for guid := 1; guid <= 2; guid++ {
+		(&BagHero{Guid: guid, Id: 100 + guid, Level: 1}).Encode(heros)
+	}
+	if err := handleTestMsg(account, proto.Bag, proto.BagSHeroInit, heros.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	update := pack.NewWriter()
+	(&BagHero{Guid: 2, Id: 102, Level: 5}).Encode(update)
+	if err := handleTestMsg(account, proto.Bag, proto.BagSHeroUpdate, update.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := handleTestMsg(account, proto.Bag, proto.BagSHeroDelete, byte(1), int16(1), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	bag := GetBagHeros(account)
+	if len(bag) != 1 || bag[2] == nil || bag[2].Level != 5 {
+		t.Fatalf("heros %v", bag)
+	}
+	if ids := heroIds(account); len(ids) != 1 || ids[2] != 102 {
+		t.Fatalf("hero ids %v", ids)
+	}
+
+	//客户端没有的英雄:更新照常保存,删除其余存在的
+	update = pack.NewWriter()
+	(&BagHero{Guid: 3, Id: 103, Level: 1}).Encode(update)
+	if err := handleTestMsg(account, proto.Bag, proto.BagSHeroUpdate, update.Bytes()); err == nil {
+		t.Fatal("update of unknown hero accepted")
+	}
+	if err := handleTestMsg(account, proto.Bag, proto.BagSHeroDelete, byte(1), int16(2), 2, 9); err == nil {
+		t.Fatal("delete of unknown hero accepted")
+	}
+	if bag := GetBagHeros(account); len(bag) != 1 || bag[3] == nil {
+		t.Fatalf("heros %v", bag)
+	}
+}
+
+func TestHandleBagEquipsAndArtis(t *testing.T) {
+	account := newTestAccount()
+	equips := pack.NewWriter(int16(2))
+	for guid := 1; guid <= 2; guid++ {
+		(&BagEquip{Guid: guid, Id: 200 + guid, Level: 1}).Encode(equips)
+	}
+	if err := handleTestMsg(account, proto.Bag, proto.BagSEquipInit, equips.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := handleTestMsg(account, proto.Bag, proto.BagSEquipDelete, byte(1), int16(1), 2); err != nil {
+		t.Fatal(err)
+	}
+	if ids := equipIds(account); len(ids) != 1 || ids[1] != 201 {
+		t.Fatalf("equip ids %v", ids)
+	}
+	update := pack.NewWriter()
+	(&BagEquip{Guid: 5, Id: 205, Level: 1}).Encode(update)
+	if err := handleTestMsg(account, proto.Bag, proto.BagSEquipUpdate, update.Bytes()); err == nil {
+		t.Fatal("update of unknown equip accepted")
+	}
+	if err := handleTestMsg(account, proto.Bag, proto.BagSEquipDelete, byte(1), int16(1), 2); err == nil {
+		t.Fatal("delete of unknown equip accepted")
+	}
+
+	arti := pack.NewWriter()
+	(&BagArti{Guid: 3, Id: 301, Attrs: []int{1, 2}, StrengLevel: []int{0, 0, 0, 0}, StrengPos: 1}).Encode(arti)
+	if err := handleTestMsg(account, proto.Bag, proto.BagSArtiInit, int16(1), arti.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	data := GetBagArtis(account)[3]
+	if data == nil || data.Id != 301 || len(data.Attrs) != 2 || len(data.StrengLevel) != 4 {
+		t.Fatalf("arti %+v", data)
+	}
+	if err := handleTestMsg(account, proto.Bag, proto.BagSArtiDelete, int16(1), 3); err != nil {
+		t.Fatal(err)
+	}
+	if len(GetBagArtis(account)) != 0 {
+		t.Fatal("arti not deleted")
+	}
+	if err := handleTestMsg(account, proto.Bag, proto.BagSArtiUpdate, arti.Bytes()); err == nil {
+		t.Fatal("update of unknown arti accepted")
+	}
+	if err := handleTestMsg(account, proto.Bag, proto.BagSArtiDelete, int16(1), 4); err == nil {
+		t.Fatal("delete of unknown arti accepted")
+	}
+}
+
+func TestHandleBagAddAwards(t *testing.T) {
+	account := newTestAccount()
+	err := handleTestMsg(account, proto.Bag, proto.BagSAddAwards, 1, int16(4),
+		//物品
+		1, 1001, 3, 13,
+		//英雄
+		7, 101, 2, 11, 12,
+		//装备
+		8, 201, 1, 21,
+		//神器
+		9, 301, 1, 31, int16(2), 5, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items := GetBagItems(account); items[1001] != 13 {
+		t.Fatalf("items %v", items)
+	}
+	if heros := GetBagHeros(account); len(heros) != 2 || heros[11].Id != 101 || heros[12].Id != 101 {
+		t.Fatalf("heros %v", heros)
+	}
+	if equips := GetBagEquips(account); len(equips) != 1 || equips[21].Id != 201 {
+		t.Fatalf("equips %v", equips)
+	}
+	if arti := GetBagArtis(account)[31]; arti == nil || arti.Id != 301 || len(arti.Attrs) != 2 || arti.Attrs[1] != 6 {
+		t.Fatalf("arti %+v", arti)
+	}
+
+	//已有13个,加3后服务器总数不是16
+	if err = handleTestMsg(account, proto.Bag, proto.BagSAddAwards, 1, int16(1), 1, 1001, 3, 20); err == nil {
+		t.Fatal("count mismatch accepted")
+	}
+	if items := GetBagItems(account); items[1001] != 20 {
+		t.Fatalf("items %v", items)
+	}
+}
+
+func TestHandleBagAddAwardsBadCount(t *testing.T) {
+	account := newTestAccount()
+	//英雄数量超过剩余字节,整条消息丢弃
+	err := handleTestMsg(account, proto.Bag, proto.BagSAddAwards, 1, int16(2), 1, 1001, 3, 13, 7, 101, 100, 11)
+	if err == nil {
+		t.Fatal("bad count accepted")
+	}
+	if len(GetBagItems(account)) != 0 || len(GetBagHeros(account)) != 0 {
+		t.Fatal("partial award committed")
+	}
+}
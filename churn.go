@@ -0,0 +1,132 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sencydai/gameworld/base"
+)
+
+const (
+	distFixed   = "fixed"
+	distUniform = "uniform"
+	distExp     = "exp"
+	distNormal  = "normal"
+)
+
+// 时长分布(秒)
+type Distribution struct {
+	//fixed uniform exp normal
+	Type   string
+	Min    float64
+	Max    float64
+	Mean   float64
+	StdDev float64
+}
+
+type ChurnConfig struct {
+	//在线时长
+	Session *Distribution
+	//下线后离线时长
+	Offline *Distribution
+	//直接断开tcp的比例(百分比),其余正常关闭websocket
+	AbruptPercent int
+	//握手过程中断开的比例(百分比)
+	HandshakePercent int
+}
+
+var (
+	churnEvents = make(map[string]uint64)
+	churnLock   sync.Mutex
+)
+
+func (dist *Distribution) Sample() time.Duration {
+	var value float64
+	switch dist.Type {
+	case distUniform:
+		value = dist.Min + rand.Float64()*(dist.Max-dist.Min)
+	case distExp:
+		value = rand.ExpFloat64() * dist.Mean
+	case distNormal:
+		value = rand.NormFloat64()*dist.StdDev + dist.Mean
+	default:
+		value = dist.Mean
+	}
+	if value < dist.Min {
+		value = dist.Min
+	}
+	if dist.Max > 0 && value > dist.Max {
+		value = dist.Max
+	}
+	value = math.Max(value, 0)
+	return time.Duration(value * float64(time.Second))
+}
+
+func countChurn(account *Account, event string) {
+	log.Printf(account, "churn: %s", event)
+
+	churnLock.Lock()
+	defer churnLock.Unlock()
+
+	churnEvents[event]++
+}
+
+func ChurnStats() map[string]uint64 {
+	churnLock.Lock()
+	defer churnLock.Unlock()
+
+	counts := make(map[string]uint64)
+	for event, count := range churnEvents {
+		counts[event] = count
+	}
+	return counts
+}
+
+// 下线并在离线时长后重新上线
+func churnOffline(account *Account) {
+	config := gConfigs.Churn
+	delay := time.Millisecond
+	if config.Offline != nil {
+		delay = config.Offline.Sample()
+	}
+	account.setOfflineDelay(delay)
+}
+
+func startChurn(account *Account) {
+	config := gConfigs.Churn
+	if config == nil || config.Session == nil {
+		return
+	}
+	AfterDelay(account, "churnLogout", config.Session.Sample(), churnLogout)
+}
+
+func churnLogout(account *Account) {
+	churnOffline(account)
+	if base.Rand(1, 100) <= gConfigs.Churn.AbruptPercent {
+		countChurn(account, "logout_abrupt")
+		account.conn.UnderlyingConn().Close()
+		return
+	}
+
+	countChurn(account, "logout_clean")
+	account.lock.Lock()
+	account.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	account.lock.Unlock()
+	account.conn.Close()
+}
+
+// 握手过程中随机断开,返回是否已断开
+func churnHandshakeDrop(account *Account) bool {
+	config := gConfigs.Churn
+	if config == nil || config.HandshakePercent <= 0 || base.Rand(1, 100) > config.HandshakePercent {
+		return false
+	}
+	churnOffline(account)
+	countChurn(account, "handshake_drop")
+	account.conn.UnderlyingConn().Close()
+	return true
+}
@@ -2,13 +2,15 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 
 	"github.com/sencydai/gameworld/base"
-	"github.com/sencydai/gameworld/proto/pack"
 	proto "github.com/sencydai/gameworld/proto/protocol"
 )
 
-type ServerMsgHandler func(*Account, *bytes.Reader)
+//go:generate go run ./cmd/msggen -in msg.schema -out msg_gen.go
+
+type ServerMsgHandler func(*Account, *MsgReader) error
 type ClientMsgHandler func(*Account)
 
 var (
@@ -44,116 +46,219 @@ func init() {
 }
 
 func RegServerHandle(sysId, cmdId byte, handle ServerMsgHandler) {
-	mark := (int(sysId) << 8) + int(cmdId)
-	serverMsgHandles[mark] = handle
+	serverMsgHandles[msgMark(sysId, cmdId)] = handle
 }
 
 func RegFightMsg(handle ClientMsgHandler) {
 	fightMsgs = append(fightMsgs, handle)
+	regClientAction(handle)
 }
 
 func RegCommonMsg(handle ClientMsgHandler) {
 	commonMsgs = append(commonMsgs, handle)
+	regClientAction(handle)
 }
 
 func HandleServereMsg(account *Account, sysId, cmdId byte, reader *bytes.Reader) {
 	defer func() {
 		if err := recover(); err != nil {
 			log.Printf(account, "handle Msg %d %d error: %v", sysId, cmdId, err)
+			countPanic()
 		}
 	}()
 
-	mark := (int(sysId) << 8) + int(cmdId)
-	handle, ok := serverMsgHandles[mark]
-	if ok {
-		//log.Printf(account, "recv %d %d", sysId, cmdId)
-		handle(account, reader)
+	countRecv(sysId, cmdId)
+	latencyOnRecv(account, sysId, cmdId)
+	resolveExpects(account, sysId, cmdId, reader)
+
+	handle, ok := serverMsgHandles[msgMark(sysId, cmdId)]
+	if !ok {
+		recordUnhandled(sysId, cmdId, reader.Len())
+		return
+	}
+	//log.Printf(account, "recv %d %d", sysId, cmdId)
+	if err := handle(account, newMsgReader(reader)); err != nil {
+		log.Printf(account, "handle Msg %s error: %s", markName(msgMark(sysId, cmdId)), err.Error())
+		countMsgError(sysId, cmdId, err)
 	}
 }
 
-func HandleLogin(account *Account, reader *bytes.Reader) {
+func HandleLogin(account *Account, reader *MsgReader) error {
 	var code byte
-	pack.Read(reader, &code)
+	reader.Values(&code)
+	if err := reader.Finish(); err != nil {
+		return err
+	}
 	if code != 0 {
 		log.Printf(account, "HandleLogin error: code(%d)", code)
+		RecordErrorCode("HandleLogin", int(code))
 		account.conn.Close()
-		return
+		return nil
 	}
 	//查询角色列表
 	account.send(proto.System, proto.SystemCActorList)
+	return nil
 }
 
-func HandleCheckActorList(account *Account, reader *bytes.Reader) {
+func HandleCheckActorList(account *Account, reader *MsgReader) error {
 	var accountId int
 	var code int
-	pack.Read(reader, &accountId, &code)
+	if err := reader.Values(&accountId, &code); err != nil {
+		return err
+	}
 	if code < 0 {
-		return
+		return nil
+	}
+
+	//code为角色数量
+	count := reader.CheckCount(code)
+	actors := make([]*ActorInfo, 0, count)
+	for i := 0; i < count; i++ {
+		var actorId float64
+		actor := &ActorInfo{}
+		reader.Values(&actorId, &actor.name, &actor.head, &actor.sex, &actor.level, &actor.job, &actor.camp)
+		actor.actorId = int64(actorId)
+		actors = append(actors, actor)
+	}
+	if err := reader.Finish(); err != nil {
+		return err
 	}
 	account.accountId = accountId
-	if code == 0 {
+	account.actors = actors
+	account.updateLogTag()
+
+	actor := chooseActor(account)
+	if actor == nil {
 		randomActorName(account)
-		return
+		return nil
 	}
-
-	var actorId float64
-	var name string
-	var head, sex, level, job, camp int
-
-	pack.Read(reader, &actorId, &name, &head, &sex, &level, &job, &camp)
-
-	sendLoginGame(account, actorId)
+	sendLoginGame(account, float64(actor.actorId))
+	return nil
 }
 
 func randomActorName(account *Account) {
-	account.send(proto.System, proto.SystemCCreateActor, "", 1, 0, 1, "pf_test")
+	config := gConfigs.Create
+	if config == nil {
+		account.createSex, account.createJob, account.createCamp = 1, 0, 1
+		sendCreateActor(account, "")
+		return
+	}
+	account.createSex = pickWeighted(config.Sex, 1)
+	account.createJob = pickWeighted(config.Job, 0)
+	account.createCamp = pickWeighted(config.Camp, 1)
+	name := createName(account, config)
+	if name != "" || !config.UseRandomName {
+		sendCreateActor(account, name)
+		return
+	}
+
+	//已收到服务器随机名称时直接使用,否则等待推送,超时后由服务器生成
+	if account.randomName != "" {
+		name, account.randomName = account.randomName, ""
+		account.createSex = account.randomSex
+		sendCreateActor(account, name)
+		return
+	}
+	account.waitRandomName = true
+	After(account, "waitRandomName", randomNameTimeout, randomNameExpired)
 }
 
-func HandleRandomActorName(account *Account, reader *bytes.Reader) {
-	var code int
-	pack.Read(reader, &code)
-	if code != 0 {
-		randomActorName(account)
+func randomNameExpired(account *Account) {
+	if !account.waitRandomName {
 		return
 	}
+	account.waitRandomName = false
+	sendCreateActor(account, "")
+}
 
+func HandleRandomActorName(account *Account, reader *MsgReader) error {
+	var code int
 	var sex int
 	var name string
-	pack.Read(reader, &sex, &name)
+	reader.Values(&code)
+	if code == 0 {
+		reader.Values(&sex, &name)
+	}
+	if err := reader.Finish(); err != nil {
+		return err
+	}
+	if code != 0 {
+		RecordErrorCode("HandleRandomActorName", code)
+		name = ""
+	}
+
+	//未在等待时保存,供下次创建使用
+	if !account.waitRandomName {
+		account.randomName, account.randomSex = name, sex
+		return nil
+	}
+	account.waitRandomName = false
+	StopTimer(account, "waitRandomName")
+	if name != "" {
+		account.createSex = sex
+	}
+	sendCreateActor(account, name)
+	return nil
 }
 
-func HandleCreateActor(account *Account, reader *bytes.Reader) {
+func HandleCreateActor(account *Account, reader *MsgReader) error {
 	var actorId float64
 	var code int
-	pack.Read(reader, &actorId, &code)
+	reader.Values(&actorId, &code)
+	if err := reader.Finish(); err != nil {
+		return err
+	}
 	if code != 0 {
+		RecordErrorCode("HandleCreateActor", code)
+		countCreate(fmt.Sprintf("error_%d", code))
+		if retryCreateActor(account) {
+			return nil
+		}
 		account.conn.Close()
-		return
+		return nil
 	}
+	countCreate("success")
+	addCreatedName(account.createName)
 	sendLoginGame(account, actorId)
+	return nil
 }
 
 func sendLoginGame(account *Account, actorId float64) {
 	account.actorId = int64(actorId)
-	account.send(proto.System, proto.SystemCLoginGame, actorId, "pf_test")
+	account.updateLogTag()
+	account.send(proto.System, proto.SystemCLoginGame, actorId, account.platform)
 }
 
-func HandleLoginSuccess(account *Account, reader *bytes.Reader) {
+func HandleLoginSuccess(account *Account, reader *MsgReader) error {
 	var code int
-	pack.Read(reader, &code)
+	reader.Values(&code)
+	if err := reader.Finish(); err != nil {
+		return err
+	}
 
 	log.Printf(account, "login game code(%d)", code)
 	if code != 0 {
+		RecordErrorCode("HandleLoginSuccess", code)
 		account.conn.Close()
-		return
+		return nil
 	}
-	serverActors[int64(account.actorId)] = gConfigs.ServerId
+	countLoginSuccess()
+	resetReconnect(account.index)
+
+	if isReplaying(account) {
+		runReplay(account, replaySessions[account.index])
+		return nil
+	}
+
+	startChurn(account)
+	addServerActor(account.actorId, account.target.ServerId)
 
 	Loop(account, "sendChatMsg", gConfigs.ChatPeriod, gConfigs.ChatPeriod, -1, sendChatMsg)
 
 	After(account, "randSendFightMsg", gConfigs.FightPeriod, randSendFightMsg)
 
 	Loop(account, "randSendCommonMsg", gConfigs.MsgPeriod, gConfigs.MsgPeriod, -1, randSendCommonMsg)
+	return nil
 }
 
 func sendChatMsg(account *Account) {
@@ -166,6 +271,10 @@ func sendChatMsg(account *Account) {
 }
 
 func randSendFightMsg(account *Account) {
+	if scenario := account.scenario; scenario != nil && scenario.fightWeight > 0 {
+		pickAction(scenario.Fight, scenario.fightWeight)(account)
+		return
+	}
 	if len(fightMsgs) == 0 {
 		return
 	}
@@ -174,10 +283,15 @@ func randSendFightMsg(account *Account) {
 }
 
 func randSendCommonMsg(account *Account) {
-	// if base.Rand(0, 5000) < 1 {
-	// 	account.conn.Close()
-	// 	return
-	// }
+	if scenario := account.scenario; scenario != nil {
+		if nextSequenceAction(account) {
+			return
+		}
+		if scenario.commonWeight > 0 {
+			pickAction(scenario.Common, scenario.commonWeight)(account)
+			return
+		}
+	}
 	if len(commonMsgs) == 0 {
 		return
 	}
@@ -187,22 +301,31 @@ func randSendCommonMsg(account *Account) {
 
 func sendEnterMainFuben(account *Account) {
 	account.send(proto.Fuben, proto.FubenCLoginMainFuben)
+	Expect(account, sendEnterMainFuben, proto.Fight, proto.FightSResult, nil)
 }
 
-func HandleFightResult(account *Account, reader *bytes.Reader) {
+func HandleFightResult(account *Account, reader *MsgReader) error {
 	var guid float64
 	var ft int
-	pack.Read(reader, &guid, &ft)
+	reader.Values(&guid, &ft)
+	if err := reader.Finish(); err != nil {
+		return err
+	}
+	if isReplaying(account) {
+		return nil
+	}
 
 	account.send(proto.Fight, proto.FightCGetAwards, ft, 0)
 
 	After(account, "randSendFightMsg", gConfigs.FightPeriod, randSendFightMsg)
+	return nil
 }
 
-func HandleChatTips(account *Account, reader *bytes.Reader) {
+func HandleChatTips(account *Account, reader *MsgReader) error {
 	var t int
 	var tips string
-	pack.Read(reader, &t, &tips)
+	reader.Values(&t, &tips)
 
 	//Print(account, "chat tips: %s", tips)
+	return reader.Finish()
 }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sencydai/gameworld/proto/pack"
+	proto "github.com/sencydai/gameworld/proto/protocol"
+)
+
+func newTestAccount() *Account {
+	return &Account{target: &Target{ServerId: 1}, data: make(map[string]interface{})}
+}
+
+// 按pack编码datas后交给sysId/cmdId注册的处理函数
+func handleTestMsg(account *Account, sysId, cmdId byte, datas ...interface{}) error {
+	handle := serverMsgHandles[msgMark(sysId, cmdId)]
+	return handle(account, newMsgReader(bytes.NewReader(pack.GetBytes(datas...))))
+}
+
+func TestHandleServereMsgCountsErrors(t *testing.T) {
+	account := newTestAccount()
+	mark := msgMark(proto.Bag, proto.BagSHeroDelete)
+	decodes := atomic.LoadUint64(&decodeErrors[mark])
+	handles := atomic.LoadUint64(&handleErrors[mark])
+
+	//数量2但只有一个guid
+	HandleServereMsg(account, proto.Bag, proto.BagSHeroDelete, bytes.NewReader(pack.GetBytes(byte(1), int16(2), 1)))
+	if got := atomic.LoadUint64(&decodeErrors[mark]); got != decodes+1 {
+		t.Fatalf("decode errors %d, want %d", got, decodes+1)
+	}
+	//删除不存在的英雄
+	HandleServereMsg(account, proto.Bag, proto.BagSHeroDelete, bytes.NewReader(pack.GetBytes(byte(1), int16(1), 1)))
+	if got := atomic.LoadUint64(&handleErrors[mark]); got != handles+1 {
+		t.Fatalf("handle errors %d, want %d", got, handles+1)
+	}
+}
+
+func TestHandleChatTipsTrailingBytes(t *testing.T) {
+	account := newTestAccount()
+	if err := handleTestMsg(account, proto.Chat, proto.ChatSTips, 1, "tips"); err != nil {
+		t.Fatal(err)
+	}
+	if err := handleTestMsg(account, proto.Chat, proto.ChatSTips, 1, "tips", byte(0)); err == nil {
+		t.Fatal("trailing bytes accepted")
+	}
+}
+
+func TestHandleRandomActorNameNotWaiting(t *testing.T) {
+	account := newTestAccount()
+	//未等待随机名称时不创建角色,保存供下次使用
+	if err := handleTestMsg(account, proto.System, proto.SystemSRandomName, 0, 2, "name"); err != nil {
+		t.Fatal(err)
+	}
+	if account.randomName != "name" || account.randomSex != 2 || account.createAttempts != 0 {
+		t.Fatalf("random name %q sex %d attempts %d", account.randomName, account.randomSex, account.createAttempts)
+	}
+}
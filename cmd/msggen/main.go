@@ -0,0 +1,280 @@
+// msggen 根据消息描述文件生成消息结构及编解码代码
+//
+// 描述文件每行一条定义, #开头为注释:
+//
+//	struct <Name> <field:type>...               嵌套结构
+//	server <Name> <field:type>...               服务器消息,生成解码及处理函数包装
+//	client <Sys>.<Cmd> <Name> <field:type>...   客户端消息,生成编码及发送函数
+//
+// 字段类型: byte int16 int uint32 float64 string, 结构名, 以及[]T(int16数量前缀的数组)
+// guid(int)和actor(float64)为需要在重放时映射的字段
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+type field struct {
+	name  string
+	typ   string
+	array bool
+}
+
+type message struct {
+	kind   string
+	sysId  string
+	cmdId  string
+	name   string
+	fields []*field
+}
+
+var basicTypes = map[string]bool{
+	"byte":    true,
+	"int16":   true,
+	"int":     true,
+	"uint32":  true,
+	"float64": true,
+	"string":  true,
+	"guid":    true,
+	"actor":   true,
+}
+
+// 映射字段对应的go类型及映射方法
+var remapTypes = map[string][2]string{
+	"guid":  {"int", "guid"},
+	"actor": {"float64", "actor"},
+}
+
+func main() {
+	in := flag.String("in", "msg.schema", "schema file")
+	out := flag.String("out", "msg_gen.go", "output file")
+	flag.Parse()
+
+	msgs, err := parse(*in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	src, err := format.Source(generate(*in, msgs))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err = ioutil.WriteFile(*out, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func parse(path string) ([]*message, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	msgs := make([]*message, 0)
+	structs := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		words := strings.Fields(text)
+		msg := &message{kind: words[0]}
+		switch msg.kind {
+		case "struct", "server":
+			if len(words) < 2 {
+				return nil, fmt.Errorf("%s:%d: missing name", path, line)
+			}
+			msg.name, words = words[1], words[2:]
+		case "client":
+			if len(words) < 3 {
+				return nil, fmt.Errorf("%s:%d: missing id or name", path, line)
+			}
+			ids := strings.Split(words[1], ".")
+			if len(ids) != 2 {
+				return nil, fmt.Errorf("%s:%d: bad id %s", path, line, words[1])
+			}
+			msg.sysId, msg.cmdId = ids[0], ids[1]
+			msg.name, words = words[2], words[3:]
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown kind %s", path, line, msg.kind)
+		}
+		if structs[msg.name] {
+			return nil, fmt.Errorf("%s:%d: duplicate name %s", path, line, msg.name)
+		}
+
+		for _, word := range words {
+			parts := strings.Split(word, ":")
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				return nil, fmt.Errorf("%s:%d: bad field %s", path, line, word)
+			}
+			f := &field{name: parts[0], typ: parts[1]}
+			if strings.HasPrefix(f.typ, "[]") {
+				f.array, f.typ = true, f.typ[2:]
+			}
+			if !basicTypes[f.typ] && !structs[f.typ] {
+				return nil, fmt.Errorf("%s:%d: unknown type %s", path, line, f.typ)
+			}
+			msg.fields = append(msg.fields, f)
+		}
+		structs[msg.name] = true
+		msgs = append(msgs, msg)
+	}
+	return msgs, scanner.Err()
+}
+
+func exported(name string) string {
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+func goType(f *field) string {
+	typ := f.typ
+	if remap, ok := remapTypes[typ]; ok {
+		typ = remap[0]
+	} else if !basicTypes[typ] {
+		typ = "*" + typ
+	}
+	if f.array {
+		typ = "[]" + typ
+	}
+	return typ
+}
+
+func generate(in string, msgs []*message) []byte {
+	buff := &bytes.Buffer{}
+	fmt.Fprintf(buff, "// Code generated by msggen from %s. DO NOT EDIT.\n\n", in)
+	fmt.Fprintf(buff, "package main\n\n")
+	fmt.Fprintf(buff, "import (\n\t\"bytes\"\n\n\t\"github.com/sencydai/gameworld/proto/pack\"\n")
+	fmt.Fprintf(buff, "\tproto \"github.com/sencydai/gameworld/proto/protocol\"\n")
+	fmt.Fprintf(buff, ")\n")
+
+	for _, msg := range msgs {
+		fmt.Fprintf(buff, "\ntype %s struct {\n", msg.name)
+		for _, f := range msg.fields {
+			fmt.Fprintf(buff, "\t%s %s\n", exported(f.name), goType(f))
+		}
+		fmt.Fprintf(buff, "}\n")
+		writeDecode(buff, msg)
+		writeEncode(buff, msg)
+		writeRemap(buff, msg)
+
+		switch msg.kind {
+		case "server":
+			fmt.Fprintf(buff, "\nfunc %sHandler(handle func(*Account, *%s) error) ServerMsgHandler {\n", msg.name, msg.name)
+			fmt.Fprintf(buff, "\treturn func(account *Account, reader *MsgReader) error {\n")
+			fmt.Fprintf(buff, "\t\tmsg := &%s{}\n\t\tmsg.Decode(reader)\n", msg.name)
+			fmt.Fprintf(buff, "\t\tif err := reader.Finish(); err != nil {\n\t\t\treturn err\n\t\t}\n")
+			fmt.Fprintf(buff, "\t\treturn handle(account, msg)\n\t}\n}\n")
+		case "client":
+			fmt.Fprintf(buff, "\nfunc (msg *%s) Send(account *Account) {\n", msg.name)
+			fmt.Fprintf(buff, "\twriter := pack.NewWriter()\n\tmsg.Encode(writer)\n")
+			fmt.Fprintf(buff, "\taccount.send(proto.%s, proto.%s, writer.Bytes())\n}\n", msg.sysId, msg.cmdId)
+		}
+	}
+
+	fmt.Fprintf(buff, "\n// 客户端消息,key为msgMark\n")
+	fmt.Fprintf(buff, "var clientMsgs = map[int]func() msgCodec{\n")
+	for _, msg := range msgs {
+		if msg.kind == "client" {
+			fmt.Fprintf(buff, "\tmsgMark(proto.%s, proto.%s): func() msgCodec { return &%s{} },\n", msg.sysId, msg.cmdId, msg.name)
+		}
+	}
+	fmt.Fprintf(buff, "}\n")
+	return buff.Bytes()
+}
+
+func writeRemap(buff *bytes.Buffer, msg *message) {
+	fmt.Fprintf(buff, "\nfunc (msg *%s) Remap(remap *guidRemap) {\n", msg.name)
+	for _, f := range msg.fields {
+		name := "msg." + exported(f.name)
+		remap, ok := remapTypes[f.typ]
+		switch {
+		case ok && f.array:
+			fmt.Fprintf(buff, "\tfor i := range %s {\n\t\t%s[i] = remap.%s(%s[i])\n\t}\n", name, name, remap[1], name)
+		case ok:
+			fmt.Fprintf(buff, "\t%s = remap.%s(%s)\n", name, remap[1], name)
+		case basicTypes[f.typ]:
+		case f.array:
+			fmt.Fprintf(buff, "\tfor i := range %s {\n\t\t%s[i].Remap(remap)\n\t}\n", name, name)
+		default:
+			fmt.Fprintf(buff, "\t%s.Remap(remap)\n", name)
+		}
+	}
+	fmt.Fprintf(buff, "}\n")
+}
+
+func writeDecode(buff *bytes.Buffer, msg *message) {
+	fmt.Fprintf(buff, "\nfunc (msg *%s) Decode(reader *MsgReader) {\n", msg.name)
+	values := make([]string, 0)
+	flush := func() {
+		if len(values) > 0 {
+			fmt.Fprintf(buff, "\treader.Values(%s)\n", strings.Join(values, ", "))
+			values = values[:0]
+		}
+	}
+	for _, f := range msg.fields {
+		name := "msg." + exported(f.name)
+		switch {
+		case f.array:
+			flush()
+			fmt.Fprintf(buff, "\t%s = make(%s, reader.Count())\n", name, goType(f))
+			fmt.Fprintf(buff, "\tfor i := range %s {\n", name)
+			if basicTypes[f.typ] {
+				fmt.Fprintf(buff, "\t\treader.Values(&%s[i])\n", name)
+			} else {
+				fmt.Fprintf(buff, "\t\t%s[i] = &%s{}\n\t\t%s[i].Decode(reader)\n", name, f.typ, name)
+			}
+			fmt.Fprintf(buff, "\t}\n")
+		case basicTypes[f.typ]:
+			values = append(values, "&"+name)
+		default:
+			flush()
+			fmt.Fprintf(buff, "\t%s = &%s{}\n\t%s.Decode(reader)\n", name, f.typ, name)
+		}
+	}
+	flush()
+	fmt.Fprintf(buff, "}\n")
+}
+
+func writeEncode(buff *bytes.Buffer, msg *message) {
+	fmt.Fprintf(buff, "\nfunc (msg *%s) Encode(writer *bytes.Buffer) {\n", msg.name)
+	values := make([]string, 0)
+	flush := func() {
+		if len(values) > 0 {
+			fmt.Fprintf(buff, "\tpack.Write(writer, %s)\n", strings.Join(values, ", "))
+			values = values[:0]
+		}
+	}
+	for _, f := range msg.fields {
+		name := "msg." + exported(f.name)
+		switch {
+		case f.array:
+			values = append(values, fmt.Sprintf("int16(len(%s))", name))
+			flush()
+			fmt.Fprintf(buff, "\tfor i := range %s {\n", name)
+			if basicTypes[f.typ] {
+				fmt.Fprintf(buff, "\t\tpack.Write(writer, %s[i])\n", name)
+			} else {
+				fmt.Fprintf(buff, "\t\t%s[i].Encode(writer)\n", name)
+			}
+			fmt.Fprintf(buff, "\t}\n")
+		case basicTypes[f.typ]:
+			values = append(values, name)
+		default:
+			flush()
+			fmt.Fprintf(buff, "\t%s.Encode(writer)\n", name)
+		}
+	}
+	flush()
+	fmt.Fprintf(buff, "}\n")
+}
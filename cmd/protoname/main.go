@@ -0,0 +1,194 @@
+// protoname 根据协议包的常量生成系统及消息名称登记代码
+//
+// 协议常量按命名区分:
+//
+//	<Sys>                 系统id
+//	<Sys>C<Cmd> <Sys>S<Cmd>  该系统的客户端/服务器消息id
+//
+// 不属于任何系统的常量视为系统
+//
+// 生成的名称必须多于机器人代码中已经引用的协议常量,
+// 否则说明读到的不是完整的协议包,生成失败
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type command struct {
+	sys  string
+	name string
+}
+
+func main() {
+	pkg := flag.String("pkg", "github.com/sencydai/gameworld/proto/protocol", "protocol package")
+	out := flag.String("out", "msgname_gen.go", "output file")
+	flag.Parse()
+
+	names, err := parse(*pkg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	used, err := referenced(filepath.Dir(*out), filepath.Base(*out))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err = checkCoverage(*pkg, names, used); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	src, err := format.Source(generate(*pkg, names))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err = ioutil.WriteFile(*out, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// 按源码顺序返回协议包中的全部常量名
+func parse(pkg string) ([]string, error) {
+	output, err := exec.Command("go", "list", "-f", "{{.Dir}}", pkg).Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list %s: %v", pkg, err)
+	}
+	dir := strings.TrimSpace(string(output))
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0)
+	asts := make(map[string]*ast.File)
+	for _, p := range pkgs {
+		for path, file := range p.Files {
+			files = append(files, path)
+			asts[path] = file
+		}
+	}
+	sort.Strings(files)
+
+	names := make([]string, 0)
+	for _, path := range files {
+		for _, decl := range asts[path].Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				for _, name := range spec.(*ast.ValueSpec).Names {
+					if name.IsExported() {
+						names = append(names, name.Name)
+					}
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+// 返回dir下除skip外的源码通过proto.X引用的协议常量名
+func referenced(dir, skip string) (map[string]bool, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return info.Name() != skip && !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+	for _, p := range pkgs {
+		for _, file := range p.Files {
+			ast.Inspect(file, func(node ast.Node) bool {
+				if sel, ok := node.(*ast.SelectorExpr); ok {
+					if ident, ok := sel.X.(*ast.Ident); ok && ident.Name == "proto" {
+						used[sel.Sel.Name] = true
+					}
+				}
+				return true
+			})
+		}
+	}
+	return used, nil
+}
+
+// 协议包中的常量全部已被引用时,生成的名称不会覆盖未处理的消息
+func checkCoverage(pkg string, names []string, used map[string]bool) error {
+	for _, name := range names {
+		if !used[name] {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s defines %d names, all already referenced by the robot; generate from the full protocol package", pkg, len(names))
+}
+
+// 拆分系统和消息,消息取最长匹配的系统名
+func split(names []string) ([]string, []*command) {
+	all := make(map[string]bool, len(names))
+	for _, name := range names {
+		all[name] = true
+	}
+
+	systems := make([]string, 0)
+	cmds := make([]*command, 0)
+	for _, name := range names {
+		sys := ""
+		for i := len(name) - 2; i > 0; i-- {
+			if c := name[i]; (c == 'C' || c == 'S') && unicode.IsUpper(rune(name[i+1])) && all[name[:i]] {
+				sys = name[:i]
+				break
+			}
+		}
+		if sys == "" {
+			systems = append(systems, name)
+		} else {
+			cmds = append(cmds, &command{sys: sys, name: name})
+		}
+	}
+	return systems, cmds
+}
+
+func generate(pkg string, names []string) []byte {
+	systems, cmds := split(names)
+
+	buff := &bytes.Buffer{}
+	fmt.Fprintf(buff, "// Code generated by protoname from %s. DO NOT EDIT.\n\n", pkg)
+	fmt.Fprintf(buff, "package main\n\n")
+	fmt.Fprintf(buff, "import proto %q\n\n", pkg)
+	fmt.Fprintf(buff, "func init() {\n")
+	for _, sys := range systems {
+		fmt.Fprintf(buff, "\tRegSysName(proto.%s, %q)\n", sys, sys)
+	}
+	for _, sys := range systems {
+		fmt.Fprintf(buff, "\n")
+		for _, cmd := range cmds {
+			if cmd.sys == sys {
+				fmt.Fprintf(buff, "\tRegMsgName(proto.%s, proto.%s, %q)\n", sys, cmd.name, cmd.name)
+			}
+		}
+	}
+	fmt.Fprintf(buff, "}\n")
+	return buff.Bytes()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	names := []string{"Bag", "BagCOpenBox", "BagSAddAwards", "BagShop", "BagShopSBuy", "System", "SystemCLogin", "Sync"}
+	systems, cmds := split(names)
+	if want := []string{"Bag", "BagShop", "System", "Sync"}; !reflect.DeepEqual(systems, want) {
+		t.Fatalf("systems %v", systems)
+	}
+	want := map[string]string{
+		"BagCOpenBox":   "Bag",
+		"BagSAddAwards": "Bag",
+		"BagShopSBuy":   "BagShop",
+		"SystemCLogin":  "System",
+	}
+	if len(cmds) != len(want) {
+		t.Fatalf("cmds %d", len(cmds))
+	}
+	for _, cmd := range cmds {
+		if want[cmd.name] != cmd.sys {
+			t.Fatalf("%s in %s", cmd.name, cmd.sys)
+		}
+	}
+}
+
+func TestCheckCoverage(t *testing.T) {
+	used := map[string]bool{"Bag": true, "BagCOpenBox": true}
+	if err := checkCoverage("proto", []string{"Bag", "BagCOpenBox"}, used); err == nil {
+		t.Fatal("names only referenced by the robot accepted")
+	}
+	if err := checkCoverage("proto", []string{"Bag", "BagCOpenBox", "BagSBuy"}, used); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReferenced(t *testing.T) {
+	used, err := referenced("testdata", "skip.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"Bag": true, "BagCOpenBox": true}; !reflect.DeepEqual(used, want) {
+		t.Fatalf("used %v", used)
+	}
+}
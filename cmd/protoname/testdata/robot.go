@@ -0,0 +1,7 @@
+package main
+
+import proto "github.com/sencydai/gameworld/proto/protocol"
+
+func sendOpenBox(account *Account) {
+	account.send(proto.Bag, proto.BagCOpenBox)
+}
@@ -0,0 +1,7 @@
+package main
+
+import proto "github.com/sencydai/gameworld/proto/protocol"
+
+func init() {
+	RegMsgName(proto.Bag, proto.BagSAddAwards, "BagSAddAwards")
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sencydai/gameworld/base"
+	proto "github.com/sencydai/gameworld/proto/protocol"
+)
+
+type WeightedValue struct {
+	Value  int
+	Weight int
+}
+
+type CreateConfig struct {
+	Sex  []*WeightedValue
+	Job  []*WeightedValue
+	Camp []*WeightedValue
+	//使用服务器推送的随机名称
+	UseRandomName bool
+	//名称模板,支持{name}{index}{rand},为空时由服务器生成
+	NameTemplate string
+	//使用已创建名称的概率(百分比),用于测试重名
+	DuplicatePercent int
+	//创建失败后重试次数
+	MaxRetries int
+}
+
+const (
+	//等待服务器随机名称的时间(秒)
+	randomNameTimeout = 5
+)
+
+var (
+	createdNames = make([]string, 0)
+	//创建统计 camp_1/sex_1/job_0/duplicate...
+	createCounts = make(map[string]int)
+	createLock   sync.Mutex
+)
+
+func pickWeighted(values []*WeightedValue, def int) int {
+	var total int
+	for _, value := range values {
+		if value.Weight > 0 {
+			total += value.Weight
+		}
+	}
+	if total == 0 {
+		return def
+	}
+	r := base.Rand(1, total)
+	for _, value := range values {
+		if value.Weight <= 0 {
+			continue
+		}
+		if r <= value.Weight {
+			return value.Value
+		}
+		r -= value.Weight
+	}
+	return def
+}
+
+func countCreate(keys ...string) {
+	createLock.Lock()
+	defer createLock.Unlock()
+
+	for _, key := range keys {
+		createCounts[key]++
+	}
+}
+
+func CreateStats() map[string]int {
+	createLock.Lock()
+	defer createLock.Unlock()
+
+	counts := make(map[string]int)
+	for key, count := range createCounts {
+		counts[key] = count
+	}
+	return counts
+}
+
+func addCreatedName(name string) {
+	if name == "" {
+		return
+	}
+	createLock.Lock()
+	defer createLock.Unlock()
+
+	createdNames = append(createdNames, name)
+}
+
+func duplicateName() string {
+	createLock.Lock()
+	defer createLock.Unlock()
+
+	if len(createdNames) == 0 {
+		return ""
+	}
+	return createdNames[base.Rand(0, len(createdNames)-1)]
+}
+
+func createName(account *Account, config *CreateConfig) string {
+	if config.DuplicatePercent > 0 && base.Rand(1, 100) <= config.DuplicatePercent {
+		if name := duplicateName(); name != "" {
+			countCreate("duplicate")
+			return name
+		}
+	}
+	if config.UseRandomName || config.NameTemplate == "" {
+		return ""
+	}
+	return strings.NewReplacer(
+		"{name}", account.accountName,
+		"{index}", fmt.Sprint(account.index),
+		"{rand}", fmt.Sprint(base.Rand(0, 99999)),
+	).Replace(config.NameTemplate)
+}
+
+func sendCreateActor(account *Account, name string) {
+	account.createName = name
+	account.createAttempts++
+	countCreate("attempt", fmt.Sprintf("sex_%d", account.createSex),
+		fmt.Sprintf("job_%d", account.createJob), fmt.Sprintf("camp_%d", account.createCamp))
+	account.send(proto.System, proto.SystemCCreateActor, name,
+		account.createSex, account.createJob, account.createCamp, account.platform)
+}
+
+// 创建失败时按配置重试,返回是否已重试
+func retryCreateActor(account *Account) bool {
+	config := gConfigs.Create
+	if config == nil || account.createAttempts > config.MaxRetries {
+		return false
+	}
+	randomActorName(account)
+	return true
+}
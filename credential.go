@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	defaultPassword = "e10adc3949ba59abbe56e057f20f883e"
+	defaultPlatform = "pf_test"
+)
+
+type Credential struct {
+	Name     string
+	Password string
+	Platform string
+}
+
+type AccountProvider interface {
+	Credential(index int) (*Credential, error)
+}
+
+type AccountSource struct {
+	//prefix(默认) csv http
+	Type string
+	//csv文件,每行 name,password,platform
+	File string
+	//http鉴权地址
+	URL      string
+	Password string
+	Platform string
+}
+
+var (
+	accountProvider AccountProvider
+)
+
+// 前缀+序号生成账号
+type prefixProvider struct {
+	prefix   string
+	password string
+	platform string
+}
+
+func (provider *prefixProvider) Credential(index int) (*Credential, error) {
+	return &Credential{
+		Name:     fmt.Sprintf("%s%d", provider.prefix, index),
+		Password: provider.password,
+		Platform: provider.platform,
+	}, nil
+}
+
+// 从csv文件读取账号,按序号循环使用
+type csvProvider struct {
+	rows []*Credential
+}
+
+func newCsvProvider(file string, password, platform string) (*csvProvider, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	provider := &csvProvider{}
+	for _, record := range records {
+		if len(record) == 0 || record[0] == "" {
+			continue
+		}
+		cred := &Credential{Name: record[0], Password: password, Platform: platform}
+		if len(record) > 1 && record[1] != "" {
+			cred.Password = record[1]
+		}
+		if len(record) > 2 && record[2] != "" {
+			cred.Platform = record[2]
+		}
+		provider.rows = append(provider.rows, cred)
+	}
+	if len(provider.rows) == 0 {
+		return nil, errors.New("no account in " + file)
+	}
+	return provider, nil
+}
+
+func (provider *csvProvider) Credential(index int) (*Credential, error) {
+	i := (index - gConfigs.StartIndex) % len(provider.rows)
+	if i < 0 {
+		i += len(provider.rows)
+	}
+	cred := *provider.rows[i]
+	return &cred, nil
+}
+
+// 通过http鉴权接口换取token作为登陆密码
+type tokenProvider struct {
+	url    string
+	base   AccountProvider
+	client *http.Client
+}
+
+type tokenRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Platform string `json:"platform"`
+}
+
+type tokenResponse struct {
+	Code     int    `json:"code"`
+	Token    string `json:"token"`
+	Platform string `json:"platform"`
+}
+
+func (provider *tokenProvider) Credential(index int) (*Credential, error) {
+	cred, err := provider.base.Credential(index)
+	if err != nil {
+		return nil, err
+	}
+	body, _ := json.Marshal(&tokenRequest{Name: cred.Name, Password: cred.Password, Platform: cred.Platform})
+	resp, err := provider.client.Post(provider.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth %s: status %d", cred.Name, resp.StatusCode)
+	}
+	result := &tokenResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, err
+	}
+	if result.Code != 0 || result.Token == "" {
+		return nil, fmt.Errorf("auth %s: code %d", cred.Name, result.Code)
+	}
+	cred.Password = result.Token
+	if result.Platform != "" {
+		cred.Platform = result.Platform
+	}
+	return cred, nil
+}
+
+func initAccountProvider() error {
+	source := gConfigs.Accounts
+	if source == nil {
+		source = &AccountSource{}
+	}
+	password, platform := source.Password, source.Platform
+	if password == "" {
+		password = defaultPassword
+	}
+	if platform == "" {
+		platform = defaultPlatform
+	}
+
+	var base AccountProvider = &prefixProvider{prefix: gConfigs.NamePrefix, password: password, platform: platform}
+	if source.File != "" {
+		provider, err := newCsvProvider(source.File, password, platform)
+		if err != nil {
+			return err
+		}
+		base = provider
+	}
+
+	switch source.Type {
+	case "", "prefix", "csv":
+		if source.Type == "csv" && source.File == "" {
+			return errors.New("csv account source without file")
+		}
+		accountProvider = base
+	case "http":
+		if source.URL == "" {
+			return errors.New("http account source without url")
+		}
+		accountProvider = &tokenProvider{url: source.URL, base: base, client: &http.Client{Timeout: 10 * time.Second}}
+	default:
+		return errors.New("unknown account source " + source.Type)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPrefixProvider(t *testing.T) {
+	provider := &prefixProvider{prefix: "robot", password: "pw", platform: "pf"}
+	cred, err := provider.Credential(12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Name != "robot12" || cred.Password != "pw" || cred.Platform != "pf" {
+		t.Fatalf("credential %+v", cred)
+	}
+}
+
+func TestCsvProvider(t *testing.T) {
+	provider, err := newCsvProvider("testdata/accounts.csv", "pw", "pf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//空账号行跳过,缺省列使用默认值
+	want := []Credential{
+		{Name: "alice", Password: "pw1", Platform: "pf_a"},
+		{Name: "bob", Password: "pw", Platform: "pf"},
+		{Name: "carol", Password: "pw", Platform: "pf_c"},
+	}
+	start := gConfigs.StartIndex
+	for i := 0; i < len(want)*2; i++ {
+		cred, err := provider.Credential(start + i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *cred != want[i%len(want)] {
+			t.Fatalf("index %d credential %+v", i, cred)
+		}
+	}
+
+	//返回副本,修改不影响后续读取
+	cred, _ := provider.Credential(start)
+	cred.Password = "token"
+	if cred, _ = provider.Credential(start); cred.Password != "pw1" {
+		t.Fatalf("row modified: %+v", cred)
+	}
+}
+
+func TestCsvProviderEmpty(t *testing.T) {
+	if _, err := newCsvProvider("testdata/empty.csv", "pw", "pf"); err == nil {
+		t.Fatal("empty csv accepted")
+	}
+	if _, err := newCsvProvider("testdata/missing.csv", "pw", "pf"); err == nil {
+		t.Fatal("missing csv accepted")
+	}
+}
+
+func TestTokenProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleMockAuth))
+	defer server.Close()
+
+	provider := &tokenProvider{
+		url:    server.URL,
+		base:   &prefixProvider{prefix: "robot", password: "pw", platform: "pf"},
+		client: server.Client(),
+	}
+	cred, err := provider.Credential(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Name != "robot3" || cred.Password != "mock_robot3" || cred.Platform != "pf" {
+		t.Fatalf("credential %+v", cred)
+	}
+}
+
+func TestTokenProviderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":5}`))
+	}))
+	defer server.Close()
+
+	provider := &tokenProvider{url: server.URL, base: &prefixProvider{prefix: "robot"}, client: server.Client()}
+	if _, err := provider.Credential(1); err == nil {
+		t.Fatal("error code accepted")
+	}
+
+	//空账号名鉴权接口返回400
+	auth := httptest.NewServer(http.HandlerFunc(handleMockAuth))
+	defer auth.Close()
+	provider = &tokenProvider{url: auth.URL, base: &csvProvider{rows: []*Credential{{}}}, client: auth.Client()}
+	if _, err := provider.Credential(1); err == nil {
+		t.Fatal("bad request accepted")
+	}
+}
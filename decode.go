@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/sencydai/gameworld/proto/pack"
+)
+
+var (
+	errShortPayload    = errors.New("payload too short")
+	errTrailingPayload = errors.New("trailing bytes in payload")
+
+	//协议不一致(包体解析失败)
+	decodeErrors [1 << 16]uint64
+	//解析成功但处理失败,如数据与客户端状态不符
+	handleErrors [1 << 16]uint64
+)
+
+// 解析错误,与处理函数返回的其他错误区分统计
+type decodeError struct {
+	err error
+}
+
+func (err *decodeError) Error() string {
+	return err.err.Error()
+}
+
+// msg.schema生成的消息
+type msgCodec interface {
+	Decode(reader *MsgReader)
+	Encode(writer *bytes.Buffer)
+	Remap(remap *guidRemap)
+}
+
+// 带错误记录的消息读取器,出错后的读取全部忽略,值保持为零
+type MsgReader struct {
+	reader *bytes.Reader
+	err    error
+}
+
+func newMsgReader(reader *bytes.Reader) *MsgReader {
+	return &MsgReader{reader: reader}
+}
+
+// 供pack.Read使用,数据不足时记录错误
+func (r *MsgReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.reader.Read(p)
+	if n < len(p) {
+		r.fail(errShortPayload)
+		return n, r.err
+	}
+	return n, err
+}
+
+func (r *MsgReader) fail(err error) {
+	if r.err == nil {
+		r.err = &decodeError{err: err}
+	}
+}
+
+func (r *MsgReader) Values(datas ...interface{}) error {
+	if r.err == nil {
+		pack.Read(r, datas...)
+	}
+	return r.err
+}
+
+// 读取int16长度前缀,负数或超过剩余字节数时记为错误并返回0
+func (r *MsgReader) Count() int {
+	var count int16
+	if r.Values(&count) != nil {
+		return 0
+	}
+	return r.CheckCount(int(count))
+}
+
+// 检查数量字段,每个元素至少占1字节
+func (r *MsgReader) CheckCount(count int) int {
+	if r.err != nil {
+		return 0
+	}
+	if count < 0 || count > r.reader.Len() {
+		r.fail(fmt.Errorf("bad count %d, %d bytes left", count, r.reader.Len()))
+		return 0
+	}
+	return count
+}
+
+func (r *MsgReader) Len() int {
+	return r.reader.Len()
+}
+
+func (r *MsgReader) Err() error {
+	return r.err
+}
+
+// 包体读取完毕,有剩余字节时记为错误
+func (r *MsgReader) Finish() error {
+	if r.err == nil && r.reader.Len() > 0 {
+		r.fail(fmt.Errorf("%w: %d bytes", errTrailingPayload, r.reader.Len()))
+	}
+	return r.err
+}
+
+func countMsgError(sysId, cmdId byte, err error) {
+	mark := msgMark(sysId, cmdId)
+	if _, ok := err.(*decodeError); ok {
+		atomic.AddUint64(&decodeErrors[mark], 1)
+	} else {
+		atomic.AddUint64(&handleErrors[mark], 1)
+	}
+}
+
+type MsgErrorStat struct {
+	Name   string `json:"name"`
+	Decode uint64 `json:"decode"`
+	Handle uint64 `json:"handle"`
+}
+
+func MsgErrorSnapshot() []*MsgErrorStat {
+	stats := make([]*MsgErrorStat, 0)
+	for mark := range decodeErrors {
+		decode := atomic.LoadUint64(&decodeErrors[mark])
+		handle := atomic.LoadUint64(&handleErrors[mark])
+		if decode == 0 && handle == 0 {
+			continue
+		}
+		stats = append(stats, &MsgErrorStat{Name: markName(mark), Decode: decode, Handle: handle})
+	}
+	return stats
+}
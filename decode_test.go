@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/sencydai/gameworld/proto/pack"
+)
+
+func newTestReader(datas ...interface{}) *MsgReader {
+	return newMsgReader(bytes.NewReader(pack.GetBytes(datas...)))
+}
+
+func isDecodeError(err error) bool {
+	_, ok := err.(*decodeError)
+	return ok
+}
+
+func TestMsgReaderCount(t *testing.T) {
+	reader := newTestReader(int16(2), 1, 2)
+	if count := reader.Count(); count != 2 || reader.Err() != nil {
+		t.Fatalf("count %d err %v", count, reader.Err())
+	}
+
+	//负数
+	reader = newTestReader(int16(-1), 1)
+	if count := reader.Count(); count != 0 || !isDecodeError(reader.Err()) {
+		t.Fatalf("count %d err %v", count, reader.Err())
+	}
+
+	//超过剩余字节数
+	reader = newTestReader(int16(5), 1)
+	if count := reader.Count(); count != 0 || !isDecodeError(reader.Err()) {
+		t.Fatalf("count %d err %v", count, reader.Err())
+	}
+
+	//前缀不完整
+	reader = newMsgReader(bytes.NewReader([]byte{1}))
+	if count := reader.Count(); count != 0 || !isDecodeError(reader.Err()) {
+		t.Fatalf("count %d err %v", count, reader.Err())
+	}
+}
+
+func TestMsgReaderCheckCount(t *testing.T) {
+	reader := newTestReader(1, 2)
+	if count := reader.CheckCount(8); count != 8 || reader.Err() != nil {
+		t.Fatalf("count %d err %v", count, reader.Err())
+	}
+	if count := reader.CheckCount(9); count != 0 || !isDecodeError(reader.Err()) {
+		t.Fatalf("count %d err %v", count, reader.Err())
+	}
+	//出错后不再检查
+	if count := reader.CheckCount(1); count != 0 {
+		t.Fatalf("count %d after error", count)
+	}
+}
+
+func TestMsgReaderShortPayload(t *testing.T) {
+	reader := newTestReader(int16(1))
+	var value, next int
+	if err := reader.Values(&value); !errors.Is(err.(*decodeError).err, errShortPayload) {
+		t.Fatalf("err %v", err)
+	}
+	//出错后的读取忽略,值保持为零
+	next = 7
+	reader.Values(&next)
+	if next != 7 || value != 0 {
+		t.Fatalf("value %d next %d", value, next)
+	}
+	if err := reader.Finish(); !isDecodeError(err) {
+		t.Fatalf("finish %v", err)
+	}
+}
+
+func TestMsgReaderFinish(t *testing.T) {
+	reader := newTestReader(1, "name")
+	var id int
+	var name string
+	reader.Values(&id, &name)
+	if err := reader.Finish(); err != nil || id != 1 || name != "name" {
+		t.Fatalf("id %d name %q err %v", id, name, err)
+	}
+
+	reader = newTestReader(1, byte(0))
+	reader.Values(&id)
+	err := reader.Finish()
+	if !isDecodeError(err) || !errors.Is(err.(*decodeError).err, errTrailingPayload) {
+		t.Fatalf("trailing err %v", err)
+	}
+	if reader.Len() != 1 {
+		t.Fatalf("len %d", reader.Len())
+	}
+}
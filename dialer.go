@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type TLSConfig struct {
+	//CA证书文件(PEM)
+	CAFile string
+	//跳过证书校验
+	InsecureSkipVerify bool
+	//SNI,为空时使用Host
+	ServerName string
+	//客户端证书及私钥
+	CertFile string
+	KeyFile  string
+}
+
+type localDialer struct {
+	addr   string
+	dialer *websocket.Dialer
+	conns  int64
+}
+
+var (
+	localDialers = []*localDialer{{dialer: websocket.DefaultDialer}}
+)
+
+func loadTLSConfig(config *TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if config.CAFile != "" {
+		data, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("no certificate found in " + config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// 自行完成tls握手以统计握手耗时
+func tlsDialFunc(dial dialFunc, tlsConfig *tls.Config) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		config := tlsConfig.Clone()
+		if config.ServerName == "" {
+			if host, _, err := net.SplitHostPort(addr); err == nil {
+				config.ServerName = host
+			} else {
+				config.ServerName = addr
+			}
+		}
+
+		start := time.Now()
+		tlsConn := tls.Client(conn, config)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		AddTiming("tls_handshake", time.Since(start))
+		return tlsConn, nil
+	}
+}
+
+func newDialer(localAddr string, tlsConfig *tls.Config) (*localDialer, error) {
+	netDialer := &net.Dialer{}
+	if localAddr != "" {
+		ip := net.ParseIP(localAddr)
+		if ip == nil {
+			return nil, errors.New("invalid local address " + localAddr)
+		}
+		netDialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+
+	dial := emulatedDial(netDialer.DialContext)
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 45 * time.Second,
+		NetDialContext:   dial,
+	}
+	if tlsConfig != nil {
+		dialer.NetDialTLSContext = tlsDialFunc(dial, tlsConfig)
+	}
+	return &localDialer{addr: localAddr, dialer: dialer}, nil
+}
+
+func initDialer() error {
+	var tlsConfig *tls.Config
+	if gConfigs.TLS != nil {
+		config, err := loadTLSConfig(gConfigs.TLS)
+		if err != nil {
+			return err
+		}
+		tlsConfig = config
+	}
+
+	addrs := gConfigs.LocalAddrs
+	if len(addrs) == 0 {
+		addrs = []string{""}
+	}
+	dialers := make([]*localDialer, 0, len(addrs))
+	for _, addr := range addrs {
+		dialer, err := newDialer(addr, tlsConfig)
+		if err != nil {
+			return err
+		}
+		dialers = append(dialers, dialer)
+	}
+	localDialers = dialers
+	return nil
+}
+
+// 按客户端序号轮流使用本地地址
+func dialerFor(i int) *localDialer {
+	return localDialers[i%len(localDialers)]
+}
+
+func LocalConnCounts() map[string]int64 {
+	counts := make(map[string]int64)
+	for _, dialer := range localDialers {
+		if dialer.addr != "" {
+			counts[dialer.addr] = atomic.LoadInt64(&dialer.conns)
+		}
+	}
+	return counts
+}
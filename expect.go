@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultExpectTimeout = 3
+)
+
+// 校验应答内容,matched为false时该消息不属于此期望,继续等待
+// matched为true时err为nil记为pass,否则记为fail
+type ExpectMatcher func(reader *MsgReader) (matched bool, err error)
+
+// 发送请求后期望收到的应答
+type expectation struct {
+	id      int
+	action  string
+	mark    int
+	matcher ExpectMatcher
+}
+
+type expectCount struct {
+	pass       uint64
+	fail       uint64
+	timeout    uint64
+	disconnect uint64
+}
+
+type ExpectStat struct {
+	Action     string `json:"action"`
+	Pass       uint64 `json:"pass"`
+	Fail       uint64 `json:"fail"`
+	Timeout    uint64 `json:"timeout"`
+	Disconnect uint64 `json:"disconnect"`
+}
+
+var (
+	expectCounts = make(map[string]*expectCount)
+	expectLock   sync.Mutex
+)
+
+func countExpect(action string, update func(*expectCount)) {
+	expectLock.Lock()
+	defer expectLock.Unlock()
+
+	count, ok := expectCounts[action]
+	if !ok {
+		count = &expectCount{}
+		expectCounts[action] = count
+	}
+	update(count)
+}
+
+func expectTimeout() time.Duration {
+	timeout := gConfigs.ExpectTimeout
+	if timeout <= 0 {
+		timeout = defaultExpectTimeout
+	}
+	return time.Second * time.Duration(timeout)
+}
+
+// 登记action发出请求后的应答期望,超时未收到记为timeout
+// matcher为空时收到消息即通过
+func Expect(account *Account, action ClientMsgHandler, sysId, cmdId byte, matcher ExpectMatcher) {
+	account.expectSeq++
+	expect := &expectation{
+		id:      account.expectSeq,
+		action:  actionName(action),
+		mark:    msgMark(sysId, cmdId),
+		matcher: matcher,
+	}
+	account.expects = append(account.expects, expect)
+	AfterDelay(account, fmt.Sprintf("expect_%d", expect.id), expectTimeout(), expectExpired, expect.id)
+}
+
+func removeExpect(account *Account, index int) *expectation {
+	expect := account.expects[index]
+	account.expects = append(account.expects[:index], account.expects[index+1:]...)
+	return expect
+}
+
+func expectExpired(account *Account, id int) {
+	for i, expect := range account.expects {
+		if expect.id == id {
+			removeExpect(account, i)
+			countExpect(expect.action, func(count *expectCount) { count.timeout++ })
+			log.Printf(account, "expect %s %s timeout", expect.action, markName(expect.mark))
+			return
+		}
+	}
+}
+
+// 收到服务器消息时按登记顺序匹配期望,由第一个认领该消息的期望记录结果
+func resolveExpects(account *Account, sysId, cmdId byte, reader *bytes.Reader) {
+	if len(account.expects) == 0 {
+		return
+	}
+	mark := msgMark(sysId, cmdId)
+	payload := make([]byte, reader.Len())
+	reader.ReadAt(payload, reader.Size()-int64(reader.Len()))
+
+	for i, expect := range account.expects {
+		if expect.mark != mark {
+			continue
+		}
+		var err error
+		if expect.matcher != nil {
+			var matched bool
+			if matched, err = expect.matcher(newMsgReader(bytes.NewReader(payload))); !matched {
+				continue
+			}
+		}
+
+		removeExpect(account, i)
+		StopTimer(account, fmt.Sprintf("expect_%d", expect.id))
+		if err != nil {
+			countExpect(expect.action, func(count *expectCount) { count.fail++ })
+			log.Printf(account, "expect %s %s fail: %s", expect.action, markName(mark), err.Error())
+		} else {
+			countExpect(expect.action, func(count *expectCount) { count.pass++ })
+		}
+		return
+	}
+}
+
+// 断线时仍在等待的期望记为disconnect,在账号goroutine退出时调用
+func flushExpects(account *Account) {
+	for _, expect := range account.expects {
+		countExpect(expect.action, func(count *expectCount) { count.disconnect++ })
+	}
+	account.expects = nil
+}
+
+// 应答中含有任一请求guid时认领,缺少其余guid记为fail
+func expectGuids(guids []int, got []int) (bool, error) {
+	set := make(map[int]bool, len(got))
+	for _, guid := range got {
+		set[guid] = true
+	}
+	var matched bool
+	var missing []int
+	for _, guid := range guids {
+		if set[guid] {
+			matched = true
+		} else {
+			missing = append(missing, guid)
+		}
+	}
+	if matched && len(missing) > 0 {
+		return true, fmt.Errorf("guids %v missing", missing)
+	}
+	return matched, nil
+}
+
+func ExpectSnapshot() []*ExpectStat {
+	expectLock.Lock()
+	defer expectLock.Unlock()
+
+	stats := make([]*ExpectStat, 0, len(expectCounts))
+	for action, count := range expectCounts {
+		stats = append(stats, &ExpectStat{
+			Action:     action,
+			Pass:       count.pass,
+			Fail:       count.fail,
+			Timeout:    count.timeout,
+			Disconnect: count.disconnect,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Action < stats[j].Action
+	})
+	return stats
+}
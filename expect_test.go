@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sencydai/gameworld/proto/pack"
+	proto "github.com/sencydai/gameworld/proto/protocol"
+)
+
+func expectStat(action ClientMsgHandler) ExpectStat {
+	name := actionName(action)
+	for _, stat := range ExpectSnapshot() {
+		if stat.Action == name {
+			return *stat
+		}
+	}
+	return ExpectStat{Action: name}
+}
+
+func resolveTestMsg(account *Account, sysId, cmdId byte, datas ...interface{}) {
+	resolveExpects(account, sysId, cmdId, bytes.NewReader(pack.GetBytes(datas...)))
+}
+
+func TestExpectOpenBoxMatchesKey(t *testing.T) {
+	account := newTestAccount()
+	defer StopAccountTimers(account)
+	before := expectStat(sendOpenBox)
+
+	Expect(account, sendOpenBox, proto.Bag, proto.BagSAddAwards, matchOpenBox(1001))
+	Expect(account, sendOpenBox, proto.Bag, proto.BagSAddAwards, matchOpenBox(1002))
+	//战斗奖励推送不属于开箱请求
+	resolveTestMsg(account, proto.Bag, proto.BagSAddAwards, awardSourceFight, int16(1), 1, 1001, 100, 110)
+	if len(account.expects) != 2 || expectStat(sendOpenBox) != before {
+		t.Fatalf("fight awards resolved open box: %+v", expectStat(sendOpenBox))
+	}
+
+	//后发的1002先应答,只认领1002的期望
+	resolveTestMsg(account, proto.Bag, proto.BagSAddAwards, awardSourceOpenBox, int16(1), 1, 1002, 3, 8)
+	if len(account.expects) != 1 || expectStat(sendOpenBox).Pass != before.Pass+1 {
+		t.Fatalf("open box 1002 not passed: %+v", expectStat(sendOpenBox))
+	}
+	resolveTestMsg(account, proto.Bag, proto.BagSAddAwards, awardSourceOpenBox, int16(1), 1, 1001, 3, 13)
+	if len(account.expects) != 0 || expectStat(sendOpenBox).Pass != before.Pass+2 {
+		t.Fatalf("open box 1001 not passed: %+v", expectStat(sendOpenBox))
+	}
+}
+
+func TestExpectDeleted(t *testing.T) {
+	account := newTestAccount()
+	defer StopAccountTimers(account)
+	before := expectStat(sendHeroDismiss)
+
+	expectDeleted(account, sendHeroDismiss, proto.BagSHeroDelete, []int{1, 2})
+	//无关的删除消息保持等待
+	resolveTestMsg(account, proto.Bag, proto.BagSHeroDelete, byte(1), int16(1), 9)
+	if len(account.expects) != 1 || expectStat(sendHeroDismiss) != before {
+		t.Fatalf("unrelated delete resolved dismiss: %+v", expectStat(sendHeroDismiss))
+	}
+	//只删除了部分guid
+	resolveTestMsg(account, proto.Bag, proto.BagSHeroDelete, byte(1), int16(1), 1)
+	if len(account.expects) != 0 || expectStat(sendHeroDismiss).Fail != before.Fail+1 {
+		t.Fatalf("partial delete not failed: %+v", expectStat(sendHeroDismiss))
+	}
+
+	expectDeleted(account, sendHeroDismiss, proto.BagSHeroDelete, []int{1, 2})
+	resolveTestMsg(account, proto.Bag, proto.BagSHeroDelete, byte(1), int16(3), 3, 2, 1)
+	if len(account.expects) != 0 || expectStat(sendHeroDismiss).Pass != before.Pass+1 {
+		t.Fatalf("delete not passed: %+v", expectStat(sendHeroDismiss))
+	}
+
+	//没有guid时不登记
+	expectDeleted(account, sendHeroDismiss, proto.BagSHeroDelete, nil)
+	if len(account.expects) != 0 {
+		t.Fatal("empty delete expected")
+	}
+}
+
+func TestExpectLordRandomNameCode(t *testing.T) {
+	//已关闭的账号send直接返回,只登记期望
+	account := newTestAccount()
+	account.closed = true
+	defer StopAccountTimers(account)
+	before := expectStat(sendLordRandomName)
+
+	sendLordRandomName(account)
+	resolveTestMsg(account, proto.Lord, proto.LordSRandomName, 1)
+	if expectStat(sendLordRandomName).Fail != before.Fail+1 {
+		t.Fatalf("error code not failed: %+v", expectStat(sendLordRandomName))
+	}
+
+	sendLordRandomName(account)
+	resolveTestMsg(account, proto.Lord, proto.LordSRandomName, 0, "name")
+	if expectStat(sendLordRandomName).Pass != before.Pass+1 {
+		t.Fatalf("random name not passed: %+v", expectStat(sendLordRandomName))
+	}
+}
+
+func TestExpectExpired(t *testing.T) {
+	account := newTestAccount()
+	defer StopAccountTimers(account)
+	before := expectStat(sendEnterMainFuben)
+
+	Expect(account, sendEnterMainFuben, proto.Fight, proto.FightSResult, nil)
+	expectExpired(account, account.expectSeq)
+	if len(account.expects) != 0 || expectStat(sendEnterMainFuben).Timeout != before.Timeout+1 {
+		t.Fatalf("not timeout: %+v", expectStat(sendEnterMainFuben))
+	}
+}
+
+func TestExpectDisconnect(t *testing.T) {
+	account := loginTestAccount(t, 6)
+	before := expectStat(sendFeedback)
+
+	//反馈没有应答,断线时仍在等待
+	inMailbox(account, func() {
+		Expect(account, sendFeedback, proto.Base, proto.BaseCFeedback, nil)
+	})
+	stopClient(6)
+	waitFor(t, func() bool {
+		return expectStat(sendFeedback).Disconnect == before.Disconnect+1
+	})
+	if stat := expectStat(sendFeedback); stat.Timeout != before.Timeout || stat.Fail != before.Fail {
+		t.Fatalf("disconnect counted as %+v", stat)
+	}
+}
+
+func TestExpectDisconnectAfterDrain(t *testing.T) {
+	account := newTestAccount()
+	account.closed = true
+	account.startMailbox()
+	defer StopAccountTimers(account)
+	before := expectStat(sendLordRandomName)
+
+	started, block := make(chan struct{}), make(chan struct{})
+	account.post(func() {
+		Expect(account, sendLordRandomName, proto.Lord, proto.LordSRandomName, nil)
+		close(started)
+		<-block
+	})
+	<-started
+	//应答已到达但排在mailbox中,关闭后丢弃,期望记为断线
+	account.post(func() {
+		resolveTestMsg(account, proto.Lord, proto.LordSRandomName, 0, "name")
+	})
+	close(account.done)
+	close(block)
+
+	waitFor(t, func() bool {
+		return expectStat(sendLordRandomName).Disconnect == before.Disconnect+1
+	})
+	if stat := expectStat(sendLordRandomName); stat.Pass != before.Pass || stat.Fail != before.Fail {
+		t.Fatalf("queued response resolved after close: %+v", stat)
+	}
+}
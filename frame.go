@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/sencydai/gameworld/proto/encrypt"
+	"github.com/sencydai/gameworld/proto/pack"
+)
+
+const (
+	defaultMaxFrameSize = 1024 * 1024
+)
+
+var (
+	errFrameTag        = errors.New("frame tag error")
+	errFrameHeaderCRC  = errors.New("frame header crc error")
+	errFrameLen        = errors.New("frame data len error")
+	errFramePayloadCRC = errors.New("frame payload crc error")
+
+	framesVerified   uint64
+	frameTagErrors   uint64
+	frameLenErrors   uint64
+	headerCRCErrors  uint64
+	payloadCRCErrors uint64
+)
+
+// 校验失败但包仍可使用时返回,调用方记录后继续处理
+type frameWarning struct {
+	err error
+}
+
+func (warning *frameWarning) Error() string {
+	return warning.err.Error()
+}
+
+// 服务器数据流解包,处理半包、粘包和超长包
+type frameDecoder struct {
+	buff     []byte
+	maxSize  int
+	hardFail bool
+	encrypt  *encrypt.Encrypt
+	//已校验并解密的当前包头
+	header []byte
+	warn   error
+}
+
+func newFrameDecoder(enc *encrypt.Encrypt, maxSize int, hardFail bool) *frameDecoder {
+	if maxSize <= 0 {
+		maxSize = defaultMaxFrameSize
+	}
+	return &frameDecoder{maxSize: maxSize, hardFail: hardFail, encrypt: enc}
+}
+
+func (decoder *frameDecoder) Feed(data []byte) {
+	decoder.buff = append(decoder.buff, data...)
+}
+
+// 解密包头校验字段并检查包头crc,返回解密后的包头
+func (decoder *frameDecoder) checkHeader(header []byte) ([]byte, error) {
+	data := make([]byte, pack.HEAD_SIZE)
+	copy(data, header)
+	decoder.encrypt.Decode(data, 8, 4)
+
+	headerCRC := make([]byte, 2)
+	copy(headerCRC, data[10:])
+	copy(data[10:], pack.GetBytes(pack.DEFAULT_CRC_KEY))
+	if !bytes.Equal(pack.GetBytes(decoder.encrypt.GetCRC16(data, pack.HEAD_SIZE)), headerCRC) {
+		return data, errFrameHeaderCRC
+	}
+	return data, nil
+}
+
+func (decoder *frameDecoder) checkPayload(frame []byte) error {
+	msgCK := pack.GetBytes(decoder.encrypt.GetCRC16ByPos(frame, pack.HEAD_SIZE, 0))
+	if !bytes.Equal(msgCK, decoder.header[8:10]) {
+		return errFramePayloadCRC
+	}
+	return nil
+}
+
+// 硬失败模式下返回错误,否则记为警告
+func (decoder *frameDecoder) fail(err error) error {
+	if decoder.hardFail {
+		return err
+	}
+	if decoder.warn == nil {
+		decoder.warn = err
+	}
+	return nil
+}
+
+// 返回下一个完整包的包体(从sysId开始),数据不足时返回nil
+// 校验失败但未开启硬失败时同时返回包体和*frameWarning
+func (decoder *frameDecoder) Next() ([]byte, error) {
+	if len(decoder.buff) < pack.HEAD_SIZE {
+		return nil, nil
+	}
+
+	var tag int
+	var dataLen int
+	pack.Read(bytes.NewReader(decoder.buff[:pack.HEAD_SIZE]), &tag, &dataLen)
+	if decoder.header == nil {
+		//包头错误无法再定位后续包,总是断开
+		if tag != pack.DEFAULT_TAG {
+			atomic.AddUint64(&frameTagErrors, 1)
+			return nil, errFrameTag
+		}
+		if dataLen < 2 || dataLen > decoder.maxSize {
+			atomic.AddUint64(&frameLenErrors, 1)
+			return nil, fmt.Errorf("%w: %d", errFrameLen, dataLen)
+		}
+		header, err := decoder.checkHeader(decoder.buff[:pack.HEAD_SIZE])
+		if err != nil {
+			atomic.AddUint64(&headerCRCErrors, 1)
+			if err = decoder.fail(err); err != nil {
+				return nil, err
+			}
+		}
+		decoder.header = header
+	}
+
+	size := pack.HEAD_SIZE + dataLen
+	if len(decoder.buff) < size {
+		return nil, nil
+	}
+	if err := decoder.checkPayload(decoder.buff[:size]); err != nil {
+		atomic.AddUint64(&payloadCRCErrors, 1)
+		if err = decoder.fail(err); err != nil {
+			return nil, err
+		}
+	}
+	frame := make([]byte, dataLen)
+	copy(frame, decoder.buff[pack.HEAD_SIZE:size])
+
+	decoder.header = nil
+	decoder.buff = decoder.buff[size:]
+	if len(decoder.buff) == 0 {
+		decoder.buff = nil
+	}
+
+	if warn := decoder.warn; warn != nil {
+		decoder.warn = nil
+		return frame, &frameWarning{err: warn}
+	}
+	atomic.AddUint64(&framesVerified, 1)
+	return frame, nil
+}
+
+func IntegrityStats() map[string]uint64 {
+	return map[string]uint64{
+		"verified":   atomic.LoadUint64(&framesVerified),
+		"tag":        atomic.LoadUint64(&frameTagErrors),
+		"len":        atomic.LoadUint64(&frameLenErrors),
+		"headerCRC":  atomic.LoadUint64(&headerCRCErrors),
+		"payloadCRC": atomic.LoadUint64(&payloadCRCErrors),
+	}
+}
+
+func (account *Account) dispatchFrames(decoder *frameDecoder) error {
+	for {
+		frame, err := decoder.Next()
+		if warning, ok := err.(*frameWarning); ok {
+			log.Printf(account, "recv warning: %s", warning.Error())
+		} else if err != nil {
+			return err
+		}
+		if frame == nil {
+			return nil
+		}
+
+		reader := bytes.NewReader(frame)
+		var sysId byte
+		var cmdId byte
+		pack.Read(reader, &sysId, &cmdId)
+		capture.Record(account, dirIn, 0, sysId, cmdId, frame[2:])
+
+		HandleServereMsg(account, sysId, cmdId, reader)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/sencydai/gameworld/proto/encrypt"
+	"github.com/sencydai/gameworld/proto/pack"
+)
+
+// 服务器和客户端两端的加密,互换salt
+func newTestEncrypts() (server, client *encrypt.Encrypt) {
+	server, client = encrypt.NewEncrypt(), encrypt.NewEncrypt()
+	server.SetTargetSalt(client.GetSelfSalt())
+	client.SetTargetSalt(server.GetSelfSalt())
+	return
+}
+
+// 按服务器格式封包,body从sysId开始
+func testFrame(enc *encrypt.Encrypt, body []byte) []byte {
+	writer := pack.NewWriter(headerData)
+	writer.Write(body)
+	data := writer.Bytes()
+	sealFrame(enc, data)
+	return data
+}
+
+// 依次Feed各分片并取出所有完整包,返回警告数,遇到错误时停止
+func decodeChunks(decoder *frameDecoder, chunks [][]byte) ([][]byte, int, error) {
+	frames := make([][]byte, 0)
+	var warnings int
+	for _, chunk := range chunks {
+		decoder.Feed(chunk)
+		for {
+			frame, err := decoder.Next()
+			if _, ok := err.(*frameWarning); ok {
+				warnings++
+			} else if err != nil {
+				return frames, warnings, err
+			}
+			if frame == nil {
+				break
+			}
+			frames = append(frames, frame)
+		}
+	}
+	return frames, warnings, nil
+}
+
+func TestFrameDecoder(t *testing.T) {
+	server, client := newTestEncrypts()
+	body1 := []byte{1, 2, 10, 11, 12}
+	body2 := []byte{3, 4}
+	frame1 := testFrame(server, body1)
+	frame2 := testFrame(server, body2)
+	merged := append(append([]byte{}, frame1...), frame2...)
+
+	badHeader := testFrame(server, body1)
+	badHeader[10] ^= 0xff
+	badTag := testFrame(server, body1)
+	badTag[0] ^= 0xff
+	badPayload := testFrame(server, body1)
+	badPayload[pack.HEAD_SIZE+2] ^= 0xff
+	corrupt := badPayload[pack.HEAD_SIZE:]
+
+	tests := []struct {
+		name     string
+		maxSize  int
+		hardFail bool
+		chunks   [][]byte
+		want     [][]byte
+		warnings int
+		err      error
+		//计数器增量,未列出的为0
+		counts map[string]uint64
+	}{
+		{name: "whole", chunks: [][]byte{frame1}, want: [][]byte{body1},
+			counts: map[string]uint64{"verified": 1}},
+		{name: "split header", chunks: [][]byte{frame1[:5], frame1[5:]}, want: [][]byte{body1},
+			counts: map[string]uint64{"verified": 1}},
+		{name: "split body", chunks: [][]byte{frame1[:pack.HEAD_SIZE+1], frame1[pack.HEAD_SIZE+1:]}, want: [][]byte{body1},
+			counts: map[string]uint64{"verified": 1}},
+		{name: "byte by byte", chunks: splitBytes(frame1), want: [][]byte{body1},
+			counts: map[string]uint64{"verified": 1}},
+		{name: "merged", chunks: [][]byte{merged}, want: [][]byte{body1, body2},
+			counts: map[string]uint64{"verified": 2}},
+		{name: "merged split", chunks: [][]byte{merged[:len(frame1)+3], merged[len(frame1)+3:]}, want: [][]byte{body1, body2},
+			counts: map[string]uint64{"verified": 2}},
+		{name: "max size", maxSize: len(body1), chunks: [][]byte{frame1}, want: [][]byte{body1},
+			counts: map[string]uint64{"verified": 1}},
+		{name: "over max size", maxSize: len(body1) - 1, chunks: [][]byte{frame1}, err: errFrameLen,
+			counts: map[string]uint64{"len": 1}},
+		{name: "tag", chunks: [][]byte{frame2, badTag}, want: [][]byte{body2}, err: errFrameTag,
+			counts: map[string]uint64{"verified": 1, "tag": 1}},
+		{name: "header crc hard", hardFail: true, chunks: [][]byte{badHeader}, err: errFrameHeaderCRC,
+			counts: map[string]uint64{"headerCRC": 1}},
+		{name: "header crc soft", chunks: [][]byte{badHeader, frame2}, want: [][]byte{body1, body2}, warnings: 1,
+			counts: map[string]uint64{"headerCRC": 1, "verified": 1}},
+		{name: "payload crc hard", hardFail: true, chunks: [][]byte{badPayload}, err: errFramePayloadCRC,
+			counts: map[string]uint64{"payloadCRC": 1}},
+		{name: "payload crc soft", chunks: [][]byte{badPayload[:pack.HEAD_SIZE+3], badPayload[pack.HEAD_SIZE+3:], frame2},
+			want: [][]byte{corrupt, body2}, warnings: 1,
+			counts: map[string]uint64{"payloadCRC": 1, "verified": 1}},
+	}
+	for _, test := range tests {
+		before := IntegrityStats()
+		decoder := newFrameDecoder(client, test.maxSize, test.hardFail)
+		frames, warnings, err := decodeChunks(decoder, test.chunks)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: err %v, want %v", test.name, err, test.err)
+		}
+		if warnings != test.warnings {
+			t.Errorf("%s: %d warnings, want %d", test.name, warnings, test.warnings)
+		}
+		for key, value := range IntegrityStats() {
+			if delta := value - before[key]; delta != test.counts[key] {
+				t.Errorf("%s: %s +%d, want +%d", test.name, key, delta, test.counts[key])
+			}
+		}
+		if len(frames) != len(test.want) {
+			t.Errorf("%s: %d frames, want %d", test.name, len(frames), len(test.want))
+			continue
+		}
+		for i := range frames {
+			if !bytes.Equal(frames[i], test.want[i]) {
+				t.Errorf("%s: frame %d %v, want %v", test.name, i, frames[i], test.want[i])
+			}
+		}
+	}
+}
+
+func splitBytes(data []byte) [][]byte {
+	chunks := make([][]byte, len(data))
+	for i := range data {
+		chunks[i] = data[i : i+1]
+	}
+	return chunks
+}
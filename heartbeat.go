@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type HeartbeatConfig struct {
+	//心跳间隔(秒)
+	Interval int
+	//读超时(秒),超时未收到任何数据视为连接已断开
+	Timeout int
+	//写超时(秒)
+	WriteTimeout int
+	//游戏心跳消息,SysId为0时使用websocket ping
+	SysId byte
+	CmdId byte
+}
+
+var (
+	idleTimeouts uint64
+)
+
+func (account *Account) touch() {
+	config := gConfigs.Heartbeat
+	if config == nil || config.Timeout <= 0 {
+		return
+	}
+	account.conn.SetReadDeadline(time.Now().Add(time.Second * time.Duration(config.Timeout)))
+}
+
+// 调用方需持有account.lock
+func (account *Account) setWriteDeadline() {
+	config := gConfigs.Heartbeat
+	if config == nil || config.WriteTimeout <= 0 {
+		return
+	}
+	account.conn.SetWriteDeadline(time.Now().Add(time.Second * time.Duration(config.WriteTimeout)))
+}
+
+func startHeartbeat(account *Account) {
+	config := gConfigs.Heartbeat
+	if config == nil {
+		return
+	}
+	account.touch()
+	account.conn.SetPongHandler(func(string) error {
+		account.touch()
+		return nil
+	})
+	if config.Interval > 0 {
+		Loop(account, "heartbeat", config.Interval, config.Interval, -1, sendHeartbeat)
+	}
+}
+
+func sendHeartbeat(account *Account) {
+	config := gConfigs.Heartbeat
+	if config.SysId == 0 {
+		account.lock.Lock()
+		defer account.lock.Unlock()
+
+		if !account.closed {
+			account.setWriteDeadline()
+			account.conn.WriteMessage(websocket.PingMessage, nil)
+		}
+		return
+	}
+	if account.Status() == statusCommunication {
+		account.send(config.SysId, config.CmdId)
+	}
+}
+
+// 读超时视为连接空闲死亡
+func checkIdleTimeout(account *Account, err error) {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		atomic.AddUint64(&idleTimeouts, 1)
+		log.Printf(account, "idle timeout, session dead")
+	}
+}
+
+func IdleTimeouts() uint64 {
+	return atomic.LoadUint64(&idleTimeouts)
+}
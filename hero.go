@@ -1,10 +1,7 @@
 package main
 
 import (
-	"bytes"
-
 	"github.com/sencydai/gameworld/base"
-	"github.com/sencydai/gameworld/proto/pack"
 	proto "github.com/sencydai/gameworld/proto/protocol"
 )
 
@@ -35,7 +32,7 @@ func GetHeroArmy(account *Account) *HeroArmyData {
 
 func init() {
 	//部队初始化
-	RegServerHandle(proto.Hero, proto.HeroSArmyInit, HandleArmyInit)
+	RegServerHandle(proto.Hero, proto.HeroSArmyInit, HeroArmyInitMsgHandler(HandleArmyInit))
 
 	//设置部队英雄位置
 	RegCommonMsg(sendSetArmyHeroPos)
@@ -61,46 +58,36 @@ func init() {
 	RegCommonMsg(sendHeroResolveArti)
 }
 
-func HandleArmyInit(account *Account, reader *bytes.Reader) {
+func HandleArmyInit(account *Account, msg *HeroArmyInitMsg) error {
 	army := GetHeroArmy(account)
 	army.fight = make(map[int]int)
 	army.assist = make(map[int]int)
-	var l int16
-	pack.Read(reader, &l)
-	for i := int16(0); i < l; i++ {
-		var pos int
-		var guid int
-		pack.Read(reader, &pos, &guid)
-		army.fight[pos] = guid
+	for _, pos := range msg.Fight {
+		army.fight[pos.Pos] = pos.Guid
 	}
-
-	pack.Read(reader, &l)
-	for i := int16(0); i < l; i++ {
-		var pos int
-		var guid int
-		pack.Read(reader, &pos, &guid)
-		army.assist[pos] = guid
+	for _, pos := range msg.Assist {
+		army.assist[pos.Pos] = pos.Guid
 	}
+	return nil
 }
 
 func sendSetArmyHeroPos(account *Account) {
 	for guid := range GetBagHeros(account) {
-		account.send(proto.Hero, proto.HeroCSetArmyHeroPos,
-			guid, base.Rand(1, 2), base.Rand(1, 12))
+		(&HeroSetArmyHeroPosMsg{Guid: guid, Army: base.Rand(1, 2), Pos: base.Rand(1, 12)}).Send(account)
 		break
 	}
 }
 
 func sendHeroOneKeyUpgrade(account *Account) {
 	for guid := range GetBagHeros(account) {
-		account.send(proto.Hero, proto.HeroCOneKeyUpgrade, guid)
+		(&HeroOneKeyUpgradeMsg{Guid: guid}).Send(account)
 		break
 	}
 }
 
 func sendHeroUpgradeStage(account *Account) {
 	for guid := range GetBagHeros(account) {
-		account.send(proto.Hero, proto.HeroCUpgradeStage, guid)
+		(&HeroUpgradeStageMsg{Guid: guid}).Send(account)
 		break
 	}
 }
@@ -108,118 +95,110 @@ func sendHeroUpgradeStage(account *Account) {
 func sendHeroChangeJob(account *Account) {
 	for guid, hero := range GetBagHeros(account) {
 		account.send(proto.Hero, proto.HeroCUpgradeStage,
-			guid, hero.id+base.Rand(-100, 200))
+			guid, hero.Id+base.Rand(-100, 200))
 		break
 	}
 }
 
 func sendHeroWearEquip(account *Account) {
 	for guid := range GetBagEquips(account) {
-		account.send(proto.Hero, proto.HeroCWearEquip,
-			base.Rand(1, 6), guid, base.Rand(0, 1))
+		(&HeroWearEquipMsg{Pos: base.Rand(1, 6), Guid: guid, Wear: base.Rand(0, 1)}).Send(account)
 		break
 	}
 }
 
 func sendHeroStrengEquip(account *Account) {
 	for guid := range GetBagEquips(account) {
-		account.send(proto.Hero, proto.HeroCStrengEquip,
-			guid, base.Rand(0, 1))
+		(&HeroStrengEquipMsg{Guid: guid, Auto: base.Rand(0, 1)}).Send(account)
 		break
 	}
 }
 
 func sendHeroResolveEquip(account *Account) {
-	equips := GetBagEquips(account)
-	count := base.Rand(0, len(equips))
-	writer := pack.NewWriter(int16(count))
-	var i int
-	for guid := range equips {
-		if i < count {
-			pack.Write(writer, guid)
-			i++
-		} else {
-			break
-		}
-	}
-	account.send(proto.Hero, proto.HeroCResolveEquip, writer.Bytes())
+	guids := randomGuids(equipIds(account))
+	(&HeroResolveEquipMsg{Guids: guids}).Send(account)
+	expectDeleted(account, sendHeroResolveEquip, proto.BagSEquipDelete, guids)
 }
 
+// 重铸和重生的应答消息不在msg.schema和协议描述中,无法判断哪条推送属于该请求,
+// 只发送不登记期望
 func sendHeroRecastEquip(account *Account) {
-	equips := GetBagEquips(account)
-	count := base.Rand(0, len(equips))
-	writer := pack.NewWriter(int16(count))
-	var i int
-	for guid := range equips {
-		if i < count {
-			pack.Write(writer, guid)
-			i++
-		} else {
-			break
-		}
-	}
-	account.send(proto.Hero, proto.HeroCRecastEquip, writer.Bytes())
+	(&HeroRecastEquipMsg{Guids: randomGuids(equipIds(account))}).Send(account)
 }
 
 func sendHeroWearArti(account *Account) {
 	for guid := range GetBagArtis(account) {
-		account.send(proto.Hero, proto.HeroCWearArti,
-			base.Rand(1, 6), guid, base.Rand(0, 1))
+		(&HeroWearArtiMsg{Pos: base.Rand(1, 6), Guid: guid, Wear: base.Rand(0, 1)}).Send(account)
 		break
 	}
 }
 
 func sendHeroStrengArti(account *Account) {
 	for guid := range GetBagArtis(account) {
-		account.send(proto.Hero, proto.HeroCStrengArti, guid)
+		(&HeroStrengArtiMsg{Guid: guid}).Send(account)
 		break
 	}
 }
 
 func sendHeroDismiss(account *Account) {
-	heros := GetBagHeros(account)
-	count := base.Rand(0, len(heros))
-	writer := pack.NewWriter(int16(count))
-	var i int
-	for guid := range heros {
-		if i < count {
-			pack.Write(writer, guid)
-			i++
-		} else {
-			break
-		}
-	}
-	account.send(proto.Hero, proto.HeroCHeroDismiss, writer.Bytes())
+	guids := randomGuids(heroIds(account))
+	(&HeroDismissMsg{Guids: guids}).Send(account)
+	expectDeleted(account, sendHeroDismiss, proto.BagSHeroDelete, guids)
 }
 
+// 应答未知,同sendHeroRecastEquip
 func sendHeroRebuild(account *Account) {
-	heros := GetBagHeros(account)
-	count := base.Rand(0, len(heros))
-	writer := pack.NewWriter(int16(count))
-	var i int
-	for guid := range heros {
-		if i < count {
-			pack.Write(writer, guid)
-			i++
-		} else {
-			break
-		}
-	}
-	account.send(proto.Hero, proto.HeroCHeroRebuild, writer.Bytes())
+	(&HeroRebuildMsg{Guids: randomGuids(heroIds(account))}).Send(account)
 }
 
 func sendHeroResolveArti(account *Account) {
-	artis := GetBagArtis(account)
-	count := base.Rand(0, len(artis))
-	writer := pack.NewWriter(int16(count))
-	var i int
-	for guid := range artis {
-		if i < count {
-			pack.Write(writer, guid)
-			i++
-		} else {
+	guids := randomGuids(artiIds(account))
+	(&HeroResolveArtiMsg{Guids: guids}).Send(account)
+	expectDeleted(account, sendHeroResolveArti, proto.BagSArtiDelete, guids)
+}
+
+// 等待背包删除应答,不含请求guid的删除消息不属于本次请求
+func expectDeleted(account *Account, action ClientMsgHandler, cmdId byte, guids []int) {
+	if len(guids) == 0 {
+		return
+	}
+	Expect(account, action, proto.Bag, cmdId, func(reader *MsgReader) (bool, error) {
+		got := deletedGuids(cmdId, reader)
+		//解析失败由处理函数统计
+		if reader.Finish() != nil {
+			return false, nil
+		}
+		return expectGuids(guids, got)
+	})
+}
+
+func deletedGuids(cmdId byte, reader *MsgReader) []int {
+	switch cmdId {
+	case proto.BagSHeroDelete:
+		msg := &BagHeroDeleteMsg{}
+		msg.Decode(reader)
+		return msg.Guids
+	case proto.BagSEquipDelete:
+		msg := &BagEquipDeleteMsg{}
+		msg.Decode(reader)
+		return msg.Guids
+	case proto.BagSArtiDelete:
+		msg := &BagArtiDeleteMsg{}
+		msg.Decode(reader)
+		return msg.Guids
+	}
+	return nil
+}
+
+// 随机取0到全部个guid
+func randomGuids(ids map[int]int) []int {
+	count := base.Rand(0, len(ids))
+	guids := make([]int, 0, count)
+	for guid := range ids {
+		if len(guids) == count {
 			break
 		}
+		guids = append(guids, guid)
 	}
-	account.send(proto.Hero, proto.HeroCResolveArti, writer.Bytes())
+	return guids
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/sencydai/gameworld/proto/pack"
+	proto "github.com/sencydai/gameworld/proto/protocol"
+)
+
+func TestHandleArmyInit(t *testing.T) {
+	account := newTestAccount()
+	army := GetHeroArmy(account)
+	army.fight[9] = 9
+
+	msg := &HeroArmyInitMsg{
+		Fight:  []*ArmyPos{{Pos: 1, Guid: 11}, {Pos: 2, Guid: 12}},
+		Assist: []*ArmyPos{{Pos: 1, Guid: 13}},
+	}
+	writer := pack.NewWriter()
+	msg.Encode(writer)
+	if err := handleTestMsg(account, proto.Hero, proto.HeroSArmyInit, writer.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	army = GetHeroArmy(account)
+	if len(army.fight) != 2 || army.fight[1] != 11 || army.fight[2] != 12 {
+		t.Fatalf("fight %v", army.fight)
+	}
+	if len(army.assist) != 1 || army.assist[1] != 13 {
+		t.Fatalf("assist %v", army.assist)
+	}
+}
+
+func TestRandomGuids(t *testing.T) {
+	ids := map[int]int{1: 101, 2: 102, 3: 103}
+	for i := 0; i < 20; i++ {
+		guids := randomGuids(ids)
+		seen := make(map[int]bool)
+		for _, guid := range guids {
+			if _, ok := ids[guid]; !ok || seen[guid] {
+				t.Fatalf("guids %v", guids)
+			}
+			seen[guid] = true
+		}
+	}
+	if guids := randomGuids(nil); len(guids) != 0 {
+		t.Fatalf("guids %v", guids)
+	}
+}
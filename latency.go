@@ -0,0 +1,204 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	proto "github.com/sencydai/gameworld/proto/protocol"
+)
+
+const (
+	latencySampleSize = 10000
+	latencyMaxPending = 64
+)
+
+type latencyHist struct {
+	count   int64
+	sum     time.Duration
+	max     time.Duration
+	samples []time.Duration
+}
+
+type LatencyStat struct {
+	Name  string  `json:"name"`
+	Count int64   `json:"count"`
+	Avg   float64 `json:"avg"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+var (
+	//应答 -> 请求
+	latencyPairs = make(map[int]int)
+	latencyHists = make(map[int]*latencyHist)
+	timingHists  = make(map[string]*latencyHist)
+	latencyLock  sync.Mutex
+)
+
+func init() {
+	//登陆
+	RegLatency(proto.System, proto.SystemCLogin, proto.System, proto.SystemSLogin)
+	//查询角色列表
+	RegLatency(proto.System, proto.SystemCActorList, proto.System, proto.SystemSActorLists)
+	//创建角色
+	RegLatency(proto.System, proto.SystemCCreateActor, proto.System, proto.SystemSCreateActor)
+	//进入游戏
+	RegLatency(proto.System, proto.SystemCLoginGame, proto.System, proto.SystemSLoginGame)
+	//主线副本
+	RegLatency(proto.Fuben, proto.FubenCLoginMainFuben, proto.Fight, proto.FightSResult)
+	//领主随机名称
+	RegLatency(proto.Lord, proto.LordCRandomName, proto.Lord, proto.LordSRandomName)
+}
+
+func msgMark(sysId, cmdId byte) int {
+	return (int(sysId) << 8) + int(cmdId)
+}
+
+func RegLatency(reqSysId, reqCmdId, respSysId, respCmdId byte) {
+	latencyPairs[msgMark(respSysId, respCmdId)] = msgMark(reqSysId, reqCmdId)
+}
+
+// 发送请求时记录时间,调用方需持有account.lock
+func latencyOnSend(account *Account, sysId, cmdId byte) {
+	mark := msgMark(sysId, cmdId)
+	if !latencyWatched(mark) {
+		return
+	}
+	if account.pending == nil {
+		account.pending = make(map[int][]time.Time)
+	}
+	times := append(account.pending[mark], time.Now())
+	if len(times) > latencyMaxPending {
+		times = times[len(times)-latencyMaxPending:]
+	}
+	account.pending[mark] = times
+}
+
+func latencyWatched(reqMark int) bool {
+	for _, mark := range latencyPairs {
+		if mark == reqMark {
+			return true
+		}
+	}
+	return false
+}
+
+func latencyOnRecv(account *Account, sysId, cmdId byte) {
+	reqMark, ok := latencyPairs[msgMark(sysId, cmdId)]
+	if !ok {
+		return
+	}
+
+	account.lock.Lock()
+	times := account.pending[reqMark]
+	if len(times) == 0 {
+		account.lock.Unlock()
+		return
+	}
+	start := times[0]
+	account.pending[reqMark] = times[1:]
+	account.lock.Unlock()
+
+	addLatency(reqMark, time.Since(start))
+}
+
+func addLatency(mark int, d time.Duration) {
+	latencyLock.Lock()
+	defer latencyLock.Unlock()
+
+	hist, ok := latencyHists[mark]
+	if !ok {
+		hist = &latencyHist{}
+		latencyHists[mark] = hist
+	}
+	hist.add(d)
+}
+
+// 记录非请求应答类的耗时,如tls握手
+func AddTiming(name string, d time.Duration) {
+	latencyLock.Lock()
+	defer latencyLock.Unlock()
+
+	hist, ok := timingHists[name]
+	if !ok {
+		hist = &latencyHist{}
+		timingHists[name] = hist
+	}
+	hist.add(d)
+}
+
+func (hist *latencyHist) add(d time.Duration) {
+	hist.count++
+	hist.sum += d
+	if d > hist.max {
+		hist.max = d
+	}
+	//蓄水池采样
+	if len(hist.samples) < latencySampleSize {
+		hist.samples = append(hist.samples, d)
+	} else if i := rand.Int63n(hist.count); i < latencySampleSize {
+		hist.samples[i] = d
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func LatencySnapshot() []*LatencyStat {
+	latencyLock.Lock()
+	defer latencyLock.Unlock()
+
+	stats := make([]*LatencyStat, 0, len(latencyHists))
+	for mark, hist := range latencyHists {
+		stats = append(stats, hist.stat(markName(mark)))
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+func TimingSnapshot() []*LatencyStat {
+	latencyLock.Lock()
+	defer latencyLock.Unlock()
+
+	stats := make([]*LatencyStat, 0, len(timingHists))
+	for name, hist := range timingHists {
+		stats = append(stats, hist.stat(name))
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+func (hist *latencyHist) stat(name string) *LatencyStat {
+	sorted := make([]time.Duration, len(hist.samples))
+	copy(sorted, hist.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return &LatencyStat{
+		Name:  name,
+		Count: hist.count,
+		Avg:   millis(hist.sum) / float64(hist.count),
+		P50:   millis(percentile(sorted, 0.5)),
+		P90:   millis(percentile(sorted, 0.9)),
+		P99:   millis(percentile(sorted, 0.99)),
+		Max:   millis(hist.max),
+	}
+}
+
+func printLatency() {
+	for _, stat := range append(LatencySnapshot(), TimingSnapshot()...) {
+		log.Printf(nil, "latency %s: count(%d) avg(%.1fms) p50(%.1fms) p90(%.1fms) p99(%.1fms) max(%.1fms)",
+			stat.Name, stat.Count, stat.Avg, stat.P50, stat.P90, stat.P99, stat.Max)
+	}
+}
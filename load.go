@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	phaseRamp  = "ramp"
+	phaseStep  = "step"
+	phaseHold  = "hold"
+	phaseSpike = "spike"
+)
+
+type LoadPhase struct {
+	//ramp线性 step阶梯 hold保持 spike突刺(持续Duration后回落)
+	Type string
+	//目标客户端数量
+	Target int
+	//持续时间(秒)
+	Duration int
+	//step阶梯数
+	Steps int
+}
+
+var (
+	//需要保持在线的客户端序号
+	wantClients = make(map[int]bool)
+	//客户端序号每次被加入时递增,旧的重连不会再拉起同一序号
+	clientGens  = make(map[int]int)
+	clientCount int
+	clientLock  sync.Mutex
+)
+
+// 序号i仍需保持在线,且gen是该序号最近一次加入时的代数
+func clientWanted(i, gen int) bool {
+	clientLock.Lock()
+	defer clientLock.Unlock()
+
+	return wantClients[i] && clientGens[i] == gen
+}
+
+// 序号仍需在线时才登记账号,避免过期的连接覆盖新连接
+func addWantedAccount(account *Account, gen int) bool {
+	clientLock.Lock()
+	defer clientLock.Unlock()
+
+	if !wantClients[account.index] || clientGens[account.index] != gen {
+		return false
+	}
+	addAccount(account)
+	return true
+}
+
+func stopClient(i int) {
+	clientLock.Lock()
+	delete(wantClients, i)
+	clientLock.Unlock()
+
+	StopTimer(nil, fmt.Sprintf("startClient_%d", i))
+
+	accountLock.RLock()
+	account := accounts[i]
+	accountLock.RUnlock()
+	if account != nil {
+		account.Close()
+	}
+}
+
+// 调整在线客户端数量,新增客户端按interval间隔启动,减少时从最后启动的开始关闭
+func setClientCount(count int, interval time.Duration) {
+	if count < 0 {
+		count = 0
+	}
+
+	clientLock.Lock()
+	cur := clientCount
+	clientCount = count
+	gens := make([]int, 0, count-cur)
+	for i := cur; i < count; i++ {
+		index := gConfigs.StartIndex + i
+		wantClients[index] = true
+		clientGens[index]++
+		gens = append(gens, clientGens[index])
+	}
+	clientLock.Unlock()
+
+	for i := cur; i < count; i++ {
+		go startClient(gConfigs.StartIndex+i, gens[i-cur])
+		if interval > 0 {
+			time.Sleep(interval)
+		}
+	}
+	for i := cur - 1; i >= count; i-- {
+		stopClient(gConfigs.StartIndex + i)
+	}
+}
+
+func currentClientCount() int {
+	clientLock.Lock()
+	defer clientLock.Unlock()
+
+	return clientCount
+}
+
+// 在period内把客户端数量调整到count,启动间隔均匀分布
+func scaleClients(count int, period time.Duration) {
+	delta := count - currentClientCount()
+	if delta <= 0 {
+		setClientCount(count, 0)
+		return
+	}
+	setClientCount(count, period/time.Duration(delta))
+}
+
+// burst为true时每阶的客户端同时启动,然后保持到该阶结束
+func runRampPhase(phase *LoadPhase, steps int, burst bool) {
+	from := currentClientCount()
+	if steps <= 0 || phase.Duration <= 0 {
+		scaleClients(phase.Target, time.Second)
+		return
+	}
+
+	period := time.Duration(phase.Duration) * time.Second / time.Duration(steps)
+	for step := 1; step <= steps; step++ {
+		start := time.Now()
+		if burst {
+			scaleClients(from+(phase.Target-from)*step/steps, 0)
+		} else {
+			scaleClients(from+(phase.Target-from)*step/steps, period)
+		}
+		if remain := period - time.Since(start); remain > 0 {
+			time.Sleep(remain)
+		}
+	}
+}
+
+func runLoadProfile(phases []*LoadPhase) {
+	for index, phase := range phases {
+		log.Printf(nil, "load phase %d: %s target(%d) duration(%d) clients(%d)",
+			index+1, phase.Type, phase.Target, phase.Duration, currentClientCount())
+
+		switch phase.Type {
+		case phaseRamp:
+			//每秒调整一次,近似线性
+			runRampPhase(phase, phase.Duration, false)
+		case phaseStep:
+			steps := phase.Steps
+			if steps <= 0 {
+				steps = 1
+			}
+			runRampPhase(phase, steps, true)
+		case phaseHold:
+			time.Sleep(time.Duration(phase.Duration) * time.Second)
+		case phaseSpike:
+			prev := currentClientCount()
+			scaleClients(phase.Target, 0)
+			time.Sleep(time.Duration(phase.Duration) * time.Second)
+			scaleClients(prev, 0)
+		default:
+			log.Printf(nil, "unknown load phase type: %s", phase.Type)
+		}
+	}
+	log.Printf(nil, "load profile finished, clients(%d)", currentClientCount())
+}
@@ -53,9 +53,8 @@ func (l *logger) Print(account *Account, data ...interface{}) {
 
 	var text string
 	if account != nil {
-		text = fmt.Sprintf("%s [%s] [%s,%d,%d] - %s\n",
-			base.FormatDateTime(time.Now()), base.FileLine(2),
-			account.accountName, account.accountId, account.actorId,
+		text = fmt.Sprintf("%s [%s] [%s] - %s\n",
+			base.FormatDateTime(time.Now()), base.FileLine(2), account.LogTag(),
 			fmt.Sprint(data...),
 		)
 	} else {
@@ -74,9 +73,8 @@ func (l *logger) Printf(account *Account, format string, data ...interface{}) {
 
 	var text string
 	if account != nil {
-		text = fmt.Sprintf("%s [%s] [%s,%d,%d] - %s\n",
-			base.FormatDateTime(time.Now()), base.FileLine(2),
-			account.accountName, account.accountId, account.actorId,
+		text = fmt.Sprintf("%s [%s] [%s] - %s\n",
+			base.FormatDateTime(time.Now()), base.FileLine(2), account.LogTag(),
 			fmt.Sprintf(format, data...),
 		)
 	} else {
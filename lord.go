@@ -1,7 +1,7 @@
 package main
 
 import (
-	"bytes"
+	"fmt"
 
 	"xgame/proto/pack"
 	proto "xgame/proto/protocol"
@@ -87,34 +87,48 @@ func init() {
 	RegCommonMsg(sendFeedback)
 }
 
-func HandleLordDecorInit(account *Account, reader *bytes.Reader) {
-	decors := GetLordDecors(account)
-	var l int16
-	pack.Read(reader, &l)
-	for i := int16(0); i < l; i++ {
+func HandleLordDecorInit(account *Account, reader *MsgReader) error {
+	added := make(map[int]*LordDecorData)
+	l := reader.Count()
+	for i := 0; i < l; i++ {
 		var (
-			t     int
-			id    int
-			count int16
+			t  int
+			id int
 		)
-		pack.Read(reader, &t, &id, &count)
+		reader.Values(&t, &id)
 		decor := &LordDecorData{id: id, unLock: make(map[int]bool)}
-		decors[t] = decor
-		for j := int16(0); j < count; j++ {
+		added[t] = decor
+		count := reader.Count()
+		for j := 0; j < count; j++ {
 			var value int
-			pack.Read(reader, &value)
+			reader.Values(&value)
 			decor.unLock[value] = true
 		}
 	}
+	if err := reader.Finish(); err != nil {
+		return err
+	}
+
+	decors := GetLordDecors(account)
+	for t, decor := range added {
+		decors[t] = decor
+	}
+	return nil
 }
 
-func HandleLordDecorUnlock(account *Account, reader *bytes.Reader) {
+func HandleLordDecorUnlock(account *Account, reader *MsgReader) error {
 	var t int
 	var id int
-	pack.Read(reader, &t, &id)
-	decors := GetLordDecors(account)
-	decor := decors[t]
+	reader.Values(&t, &id)
+	if err := reader.Finish(); err != nil {
+		return err
+	}
+	decor, ok := GetLordDecors(account)[t]
+	if !ok {
+		return fmt.Errorf("not find decor type(%d)", t)
+	}
 	decor.unLock[id] = true
+	return nil
 }
 
 func sendDecorChange(account *Account) {
@@ -135,30 +149,45 @@ func sendDecorChange(account *Account) {
 	}
 }
 
-func HandleLordEquipInit(account *Account, reader *bytes.Reader) {
+func HandleLordEquipInit(account *Account, reader *MsgReader) error {
 	var strengPos int
-	var l int16
-	pack.Read(reader, &strengPos, &l)
-	equips := GetLordEquips(account)
-	for i := int16(1); i <= l; i++ {
+	reader.Values(&strengPos)
+	l := reader.Count()
+	added := make([]*LordEquipData, 0, l)
+	for i := 0; i < l; i++ {
 		equip := &LordEquipData{}
-		pack.Read(reader, &equip.stage, &equip.level)
-		equips[int(i)] = equip
+		reader.Values(&equip.stage, &equip.level)
+		added = append(added, equip)
 	}
+	if err := reader.Finish(); err != nil {
+		return err
+	}
+
+	equips := GetLordEquips(account)
+	for i, equip := range added {
+		equips[i+1] = equip
+	}
+	return nil
 }
 
-func HandleLordRandomName(account *Account, reader *bytes.Reader) {
+func HandleLordRandomName(account *Account, reader *MsgReader) error {
 	var (
 		code int
 		name string
 	)
-	pack.Read(reader, &code)
-	if code != 0 {
-		return
+	reader.Values(&code)
+	if code == 0 {
+		reader.Values(&name)
+	}
+	if err := reader.Finish(); err != nil {
+		return err
+	}
+	if code != 0 || isReplaying(account) {
+		return nil
 	}
-	pack.Read(reader, &name)
 
 	account.send(proto.Lord, proto.LordCChangeName, name)
+	return nil
 }
 
 func sendLordEquipStreng(account *Account) {
@@ -182,21 +211,29 @@ func sendLordGetVipAwards(account *Account) {
 }
 
 func sendLordLookupLord(account *Account) {
-	for actorId, serverId := range serverActors {
-		account.send(proto.Lord, proto.LordCLookupLord, 0, "", serverId, float64(actorId), "")
-		break
+	if actorId, serverId, ok := randomServerActor(account.target.ServerId); ok {
+		(&LordLookupLordMsg{ServerId: serverId, ActorId: float64(actorId)}).Send(account)
 	}
 }
 
 func sendLordLookupHero(account *Account) {
-	for actorId, serverId := range serverActors {
-		account.send(proto.Lord, proto.LordCLookupHero, 0, "", serverId, float64(actorId), base.Rand(0, 20))
-		break
+	if actorId, serverId, ok := randomServerActor(account.target.ServerId); ok {
+		(&LordLookupHeroMsg{ServerId: serverId, ActorId: float64(actorId), HeroPos: base.Rand(0, 20)}).Send(account)
 	}
 }
 
 func sendLordRandomName(account *Account) {
 	account.send(proto.Lord, proto.LordCRandomName)
+	Expect(account, sendLordRandomName, proto.Lord, proto.LordSRandomName, func(reader *MsgReader) (bool, error) {
+		var code int
+		if reader.Values(&code) != nil {
+			return false, nil
+		}
+		if code != 0 {
+			return true, fmt.Errorf("code %d", code)
+		}
+		return true, nil
+	})
 }
 
 func sendLordSkillStage(account *Account) {
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	proto "github.com/sencydai/gameworld/proto/protocol"
+)
+
+func TestHandleLordDecor(t *testing.T) {
+	account := newTestAccount()
+	//类型1当前装饰1,已解锁1
+	if err := handleTestMsg(account, proto.Lord, proto.LordSDecorInit, int16(1), 1, 1, int16(1), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := handleTestMsg(account, proto.Lord, proto.LordSDecorUnlock, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	decor := GetLordDecors(account)[1]
+	if decor == nil || decor.id != 1 || !decor.unLock[1] || !decor.unLock[2] {
+		t.Fatalf("decor %+v", decor)
+	}
+
+	//未初始化的类型
+	if err := handleTestMsg(account, proto.Lord, proto.LordSDecorUnlock, 2, 1); err == nil {
+		t.Fatal("unknown decor type accepted")
+	}
+}
+
+func TestHandleLordEquipInit(t *testing.T) {
+	account := newTestAccount()
+	if err := handleTestMsg(account, proto.Lord, proto.LordSEquipInit, 1, int16(2), 1, 2, 3, 4); err != nil {
+		t.Fatal(err)
+	}
+	equips := GetLordEquips(account)
+	if len(equips) != 2 || equips[1].stage != 1 || equips[1].level != 2 || equips[2].stage != 3 || equips[2].level != 4 {
+		t.Fatalf("equips %v", equips)
+	}
+}
+
+func TestHandleLordRandomNameError(t *testing.T) {
+	account := newTestAccount()
+	//错误码后没有名称,不发送改名
+	if err := handleTestMsg(account, proto.Lord, proto.LordSRandomName, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := handleTestMsg(account, proto.Lord, proto.LordSRandomName, 1, "name"); err == nil {
+		t.Fatal("trailing name accepted")
+	}
+}
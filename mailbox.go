@@ -0,0 +1,87 @@
+package main
+
+import (
+	"sync/atomic"
+)
+
+const (
+	mailboxSize = 256
+)
+
+var (
+	//账号关闭后丢弃的消息和回调
+	droppedPosts uint64
+)
+
+// 账号状态(data、背包、部队等)只在账号自己的goroutine中读写
+// 收到的数据和定时器回调都投递到mailbox中依次执行
+func (account *Account) startMailbox() {
+	account.mailbox = make(chan func(), mailboxSize)
+	account.done = make(chan struct{})
+	go account.runMailbox()
+}
+
+func (account *Account) runMailbox() {
+	for {
+		select {
+		case fn := <-account.mailbox:
+			//与done同时就绪时select随机选择,关闭后不再执行
+			if account.isDone() {
+				atomic.AddUint64(&droppedPosts, 1)
+				account.exitMailbox()
+				return
+			}
+			fn()
+		case <-account.done:
+			account.exitMailbox()
+			return
+		}
+	}
+}
+
+// 先丢弃未执行的消息,仍在等待的期望不会再被应答,记为断线
+func (account *Account) exitMailbox() {
+	account.drainMailbox()
+	flushExpects(account)
+}
+
+// 关闭时丢弃mailbox中尚未执行的消息和回调并计数,之后账号状态不再变化
+func (account *Account) drainMailbox() {
+	for {
+		select {
+		case <-account.mailbox:
+			atomic.AddUint64(&droppedPosts, 1)
+		default:
+			return
+		}
+	}
+}
+
+func (account *Account) isDone() bool {
+	select {
+	case <-account.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// 投递到账号goroutine执行,账号已关闭时丢弃并返回false
+func (account *Account) post(fn func()) bool {
+	//mailbox有空位时select随机选择,先检查是否已关闭
+	if account.isDone() {
+		atomic.AddUint64(&droppedPosts, 1)
+		return false
+	}
+	select {
+	case account.mailbox <- fn:
+		return true
+	case <-account.done:
+		atomic.AddUint64(&droppedPosts, 1)
+		return false
+	}
+}
+
+func DroppedPosts() uint64 {
+	return atomic.LoadUint64(&droppedPosts)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	proto "github.com/sencydai/gameworld/proto/protocol"
+)
+
+// 登陆后的账号,消息处理与测试中的定时器回调并发到达
+func loginTestAccount(t *testing.T, i int) *Account {
+	setupMockTest(t)
+	account := startTestClient(t, i)
+	waitFor(t, func() bool {
+		var ok bool
+		inMailbox(account, func() { ok = account.actorId != 0 })
+		return ok
+	})
+	return account
+}
+
+func TestMailboxSerializesTimers(t *testing.T) {
+	account := loginTestAccount(t, 3)
+
+	//定时器回调与消息处理都读写account.data,-race下不应报告竞争
+	const count = 50
+	for i := 0; i < count; i++ {
+		AfterDelay(account, fmt.Sprintf("test_%d", i), time.Millisecond*time.Duration(i%5), func(account *Account) {
+			total, _ := account.data["test"].(int)
+			account.data["test"] = total + 1
+			GetBagItems(account)
+		})
+	}
+	Loop(account, "testLoop", 0, 1, 3, func(account *Account) {
+		total, _ := account.data["loop"].(int)
+		account.data["loop"] = total + 1
+	})
+
+	waitFor(t, func() bool {
+		var total, loop int
+		inMailbox(account, func() {
+			total, _ = account.data["test"].(int)
+			loop, _ = account.data["loop"].(int)
+		})
+		return total == count && loop == 3
+	})
+	if !IsStoped(account, "testLoop") {
+		t.Fatal("loop not stopped")
+	}
+}
+
+func TestMailboxLogDuringLogin(t *testing.T) {
+	//已关闭的账号send直接返回,只验证处理函数与日志的并发
+	account := newTestAccount()
+	account.accountName = "log"
+	account.closed = true
+	account.startMailbox()
+	defer close(account.done)
+
+	//其他goroutine打印日志时读取账号标识,与账号goroutine中的修改并发
+	done := make(chan struct{})
+	stop := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				log.Printf(account, "log during login")
+			}
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		result := make(chan error, 1)
+		account.post(func() {
+			result <- handleTestMsg(account, proto.System, proto.SystemSActorLists, 10+i, 1,
+				float64(100+i), "actor", 1, 1, 1, 0, 1)
+		})
+		if err := <-result; err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	<-done
+
+	if tag := account.LogTag(); tag != "log,19,109" {
+		t.Fatalf("log tag %s", tag)
+	}
+}
+
+func TestMailboxClosedAccount(t *testing.T) {
+	account := loginTestAccount(t, 5)
+
+	fired := make(chan struct{}, 1)
+	AfterDelay(account, "testClosed", time.Millisecond*50, func(account *Account) {
+		fired <- struct{}{}
+	})
+	stopClient(5)
+
+	if account.post(func() {}) {
+		t.Fatal("post to closed account")
+	}
+	if !IsStoped(account, "testClosed") {
+		t.Fatal("timer not stopped on close")
+	}
+	select {
+	case <-fired:
+		t.Fatal("timer fired after close")
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+func TestMailboxDropsQueuedOnClose(t *testing.T) {
+	account := newTestAccount()
+	account.closed = true
+	account.startMailbox()
+
+	//第一个回调阻塞账号goroutine,其余留在mailbox中
+	block := make(chan struct{})
+	account.post(func() { <-block })
+	var ran int32
+	const queued = 5
+	for i := 0; i < queued; i++ {
+		account.post(func() { atomic.AddInt32(&ran, 1) })
+	}
+
+	dropped := DroppedPosts()
+	close(account.done)
+	close(block)
+	if account.post(func() { atomic.AddInt32(&ran, 1) }) {
+		t.Fatal("post to closed account")
+	}
+
+	//计数为全局值,其他测试的账号关闭时也会增加
+	waitFor(t, func() bool { return DroppedPosts()-dropped >= queued+1 && len(account.mailbox) == 0 })
+	if n := atomic.LoadInt32(&ran); n != 0 {
+		t.Fatalf("%d callbacks ran after close", n)
+	}
+}
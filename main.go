@@ -1,19 +1,17 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"net/url"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/sencydai/gameworld/proto/encrypt"
-	"github.com/sencydai/gameworld/proto/pack"
 )
 
 type connectStatus int
@@ -36,25 +34,103 @@ type GlobalConfig struct {
 	ChatPeriod  int
 	MsgPeriod   int
 	ChatMsgs    []string `json:chatMsgs`
+
+	//延迟统计输出周期(秒),0不输出
+	LatencyPeriod int
+	//统计http端口,0不开启
+	StatsPort int
+	//统计http监听地址,默认127.0.0.1
+	StatsHost string
+	//行为场景配置文件,为空时均匀随机
+	ScenarioFile string
+	//负载阶段,为空时直接启动ClientCount个客户端
+	Profile []*LoadPhase
+	//退出时输出报告路径(不含后缀),为空不输出
+	ReportPath string
+	//抓包文件,为空不抓包
+	RecordFile string
+	//重放抓包文件,为空时正常运行
+	ReplayFile string
+	//重放速度倍率,默认1
+	ReplaySpeed float64
+	//在Host上启动模拟服务器
+	Mock bool
+	//服务器单包最大长度,默认1M
+	MaxFrameSize int
+	//服务器包crc校验失败时断开连接,否则只统计
+	CRCHardFail bool
+	//重连策略,为空时连接失败15秒、断线300秒后重连
+	//指数退避示例: "reconnect": {"initial": 5, "multiplier": 2, "max": 300, "jitter": 0.2, "maxAttempts": 0}
+	Reconnect *ReconnectPolicy
+	//wss配置
+	TLS *TLSConfig
+	//本地源地址,客户端轮流绑定
+	LocalAddrs []string
+	//目标服务器列表,为空时使用Scheme/Host/ServerId
+	Targets []*Target
+	//账号来源,为空时使用NamePrefix+序号
+	Accounts *AccountSource
+	//创建角色配置,为空时固定sex 1,job 0,camp 1
+	Create *CreateConfig
+	//多角色选择策略 first(默认) random newest create
+	ActorPolicy string
+	//create策略下每个账号创建的角色数量
+	MaxActors int
+	//上下线模拟,为空不模拟
+	Churn *ChurnConfig
+	//心跳及超时检测,为空不开启
+	Heartbeat *HeartbeatConfig
+	//网络环境模拟,按Ratio分配账号
+	NetProfiles []*NetProfile
+	//应答期望超时(秒),默认3
+	ExpectTimeout int
 }
 
 var (
-	gConfigs     = &GlobalConfig{}
-	serverActors = make(map[int64]int)
+	gConfigs = &GlobalConfig{}
 )
 
-func startClient(i int) {
-	u := url.URL{Scheme: gConfigs.Scheme, Host: gConfigs.Host}
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+func startClient(i, gen int) {
+	if !clientWanted(i, gen) {
+		return
+	}
+	cred, err := accountProvider.Credential(i)
+	if err != nil {
+		log.Printf(nil, "client %d credential error: %s", i, err.Error())
+		scheduleReconnect(i, gen, true)
+		return
+	}
+	if session, ok := replaySessions[i]; ok {
+		cred.Name = session.name
+	}
+
+	target := targetFor(i)
+	u := url.URL{Scheme: target.Scheme, Host: target.Host}
+	atomic.AddInt64(&dialing, 1)
+	start := time.Now()
+	dialer := dialerFor(i)
+	profile := netProfileFor(i)
+	conn, _, err := dialer.dialer.DialContext(withNetProfile(context.Background(), profile), u.String(), nil)
+	atomic.AddInt64(&dialing, -1)
 	if err != nil {
-		After(nil, fmt.Sprintf("startClient_%d", i), 15, startClient, i)
+		scheduleReconnect(i, gen, true)
 		return
 	}
+	AddTiming("dial", time.Since(start))
+	atomic.AddInt64(&dialer.conns, 1)
+	defer atomic.AddInt64(&dialer.conns, -1)
+
 	account := &Account{
+		index:       i,
+		target:      target,
+		netProfile:  profile,
 		conn:        conn,
 		connStatus:  statusChecking,
 		encrypt:     encrypt.NewEncrypt(),
-		accountName: fmt.Sprintf("%s%d", gConfigs.NamePrefix, i),
+		accountName: cred.Name,
+		password:    cred.Password,
+		platform:    cred.Platform,
+		scenario:    pickScenario(i),
 		data:        make(map[string]interface{}),
 	}
 
@@ -63,54 +139,43 @@ func startClient(i int) {
 			log.Print(account, err)
 		}
 		account.Close()
-		After(nil, fmt.Sprintf("startClient_%d", i), 300, startClient, i)
+		if delay := account.OfflineDelay(); delay > 0 {
+			restartClient(i, gen, delay)
+		} else {
+			scheduleReconnect(i, gen, false)
+		}
 	}()
 
+	account.startMailbox()
+	if !addWantedAccount(account, gen) {
+		return
+	}
+
 	account.onConnect()
+	startHeartbeat(account)
 
-	buff := make([]byte, 0)
-	reader := bytes.NewReader(buff)
+	decoder := newFrameDecoder(account.encrypt, gConfigs.MaxFrameSize, gConfigs.CRCHardFail)
 	//读数据
 	for {
 		_, data, err := account.conn.ReadMessage()
 		if err != nil {
 			log.Printf(account, "recv error: %s", err.Error())
+			checkIdleTimeout(account, err)
 			break
 		}
-		if account.connStatus < statusCommunication {
-			account.setTargetSalt(data)
-			continue
-		}
-		buff = append(buff, data...)
-		if len(buff) < pack.HEAD_SIZE {
-			continue
-		}
-		reader.Reset(buff)
-		var tag int
-		pack.Read(reader, &tag)
-		if tag != pack.DEFAULT_TAG {
-			log.Printf(account, "recv error error default tag %v", buff)
-			break
-		}
-
-		var dataLen int
-		pack.Read(reader, &dataLen)
-		if dataLen < 2 {
-			log.Printf(account, "recv error data len %v", buff)
-			break
-		}
-		size := pack.HEAD_SIZE + dataLen
-		if len(data) < size {
-			continue
-		}
-		data = buff[pack.HEAD_SIZE:size]
-		buff = buff[size:]
-		reader.Reset(data)
-		var sysId byte
-		var cmdId byte
-		pack.Read(reader, &sysId, &cmdId)
-
-		HandleServereMsg(account, sysId, cmdId, reader)
+		account.touch()
+		//握手、解包和消息处理都在账号goroutine中进行
+		account.post(func() {
+			if account.Status() < statusCommunication {
+				account.setTargetSalt(data)
+				return
+			}
+			decoder.Feed(data)
+			if err := account.dispatchFrames(decoder); err != nil {
+				log.Printf(account, "recv error: %s", err.Error())
+				account.Close()
+			}
+		})
 	}
 }
 
@@ -131,13 +196,76 @@ func main() {
 		return
 	}
 
-	for i := gConfigs.StartIndex; i < (gConfigs.StartIndex + gConfigs.ClientCount); i++ {
-		go startClient(i)
-		time.Sleep(time.Millisecond * 50)
+	if gConfigs.ScenarioFile != "" {
+		if err := loadScenarios(gConfigs.ScenarioFile); err != nil {
+			log.Print(nil, err.Error())
+			return
+		}
+	}
+
+	if gConfigs.RecordFile != "" {
+		if err := openRecorder(gConfigs.RecordFile); err != nil {
+			log.Print(nil, err.Error())
+			return
+		}
+		defer capture.Close()
+	}
+
+	if err := initDialer(); err != nil {
+		log.Print(nil, err.Error())
+		return
+	}
+
+	initTargets()
+	initNetProfiles()
+	if err := initAccountProvider(); err != nil {
+		log.Print(nil, err.Error())
+		return
+	}
+
+	if gConfigs.Mock {
+		hosts := make(map[string]bool)
+		for _, target := range targets {
+			if hosts[target.Host] {
+				continue
+			}
+			hosts[target.Host] = true
+			if _, err := startMockServer(target.Host); err != nil {
+				log.Print(nil, err.Error())
+				return
+			}
+		}
+	}
+
+	runStart = time.Now()
+	Loop(nil, "sampleOnline", 1, 1, -1, sampleOnline)
+
+	if gConfigs.StatsPort > 0 {
+		startStatsServer(gConfigs.StatsHost, gConfigs.StatsPort)
+	}
+
+	if gConfigs.LatencyPeriod > 0 {
+		Loop(nil, "printLatency", gConfigs.LatencyPeriod, gConfigs.LatencyPeriod, -1, printLatency)
+	}
+
+	if gConfigs.ReplayFile != "" {
+		if err := startReplay(gConfigs.ReplayFile); err != nil {
+			log.Print(nil, err.Error())
+			return
+		}
+	} else if len(gConfigs.Profile) > 0 {
+		go runLoadProfile(gConfigs.Profile)
+	} else {
+		setClientCount(gConfigs.ClientCount, time.Millisecond*50)
 	}
 
 	signalC := make(chan os.Signal, 1)
 	signal.Notify(signalC, os.Interrupt, os.Kill)
 
 	<-signalC
+
+	printUnhandled()
+	if gConfigs.ReportPath != "" {
+		writeReport(gConfigs.ReportPath)
+	}
 }
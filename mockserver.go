@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/sencydai/gameworld/proto/encrypt"
+	"github.com/sencydai/gameworld/proto/pack"
+	proto "github.com/sencydai/gameworld/proto/protocol"
+)
+
+type MockMsgHandler func(*mockSession, *bytes.Reader)
+
+// 模拟服务器上的账号数据
+type mockAccount struct {
+	accountId int
+	actors    []*mockActor
+}
+
+type mockActor struct {
+	actorId int64
+	name    string
+	sex     int
+}
+
+type mockSession struct {
+	conn    *websocket.Conn
+	encrypt *encrypt.Encrypt
+	lock    sync.Mutex
+
+	account *mockAccount
+	heros   map[int]int
+	equips  map[int]int
+	//物品和货币数量,用于奖励中的总数
+	items map[int]int
+}
+
+var (
+	mockMsgHandles = make(map[int]MockMsgHandler)
+	mockAccounts   = make(map[string]*mockAccount)
+	mockActorNames = make(map[string]bool)
+	mockGuid       int
+	mockLock       sync.Mutex
+
+	mockUpgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+)
+
+func init() {
+	//登陆
+	RegMockHandle(proto.System, proto.SystemCLogin, mockLogin)
+	//查询角色列表
+	RegMockHandle(proto.System, proto.SystemCActorList, mockActorList)
+	//创建角色
+	RegMockHandle(proto.System, proto.SystemCCreateActor, mockCreateActor)
+	//进入游戏
+	RegMockHandle(proto.System, proto.SystemCLoginGame, mockLoginGame)
+	//主线副本
+	RegMockHandle(proto.Fuben, proto.FubenCLoginMainFuben, mockEnterMainFuben)
+	//领取战斗奖励
+	RegMockHandle(proto.Fight, proto.FightCGetAwards, mockGetAwards)
+	//开启宝箱
+	RegMockHandle(proto.Bag, proto.BagCOpenBox, mockOpenBox)
+	//英雄遣散
+	RegMockHandle(proto.Hero, proto.HeroCHeroDismiss, mockHeroDismiss)
+	//装备分解
+	RegMockHandle(proto.Hero, proto.HeroCResolveEquip, mockResolveEquip)
+	//领主随机名称
+	RegMockHandle(proto.Lord, proto.LordCRandomName, mockLordRandomName)
+}
+
+func RegMockHandle(sysId, cmdId byte, handle MockMsgHandler) {
+	mockMsgHandles[msgMark(sysId, cmdId)] = handle
+}
+
+func newMockGuid() int {
+	mockLock.Lock()
+	defer mockLock.Unlock()
+
+	mockGuid++
+	return mockGuid
+}
+
+// 在addr上启动模拟服务器,返回实际监听地址
+func startMockServer(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handleMockConn)
+	mux.HandleFunc("/auth", handleMockAuth)
+
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Printf(nil, "mock server error: %s", err.Error())
+		}
+	}()
+	return listener.Addr().String(), nil
+}
+
+// 模拟http鉴权接口,供tokenProvider使用
+func handleMockAuth(w http.ResponseWriter, r *http.Request) {
+	request := &tokenRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil || request.Name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&tokenResponse{Token: "mock_" + request.Name, Platform: request.Platform})
+}
+
+func handleMockConn(w http.ResponseWriter, r *http.Request) {
+	conn, err := mockUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	session := &mockSession{
+		conn:    conn,
+		encrypt: encrypt.NewEncrypt(),
+		heros:   make(map[int]int),
+		equips:  make(map[int]int),
+		items:   make(map[int]int),
+	}
+
+	//握手: 客户端salt -> 服务器salt -> 客户端checkKey
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return
+	}
+	var salt uint32
+	pack.Read(bytes.NewReader(data), &salt)
+	session.encrypt.SetTargetSalt(salt)
+	conn.WriteMessage(websocket.BinaryMessage, pack.GetBytes(session.encrypt.GetSelfSalt()))
+	if _, _, err = conn.ReadMessage(); err != nil {
+		return
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if len(data) < pack.HEAD_SIZE+6 {
+			log.Printf(nil, "mock server: short frame %v", data)
+			return
+		}
+		reader := bytes.NewReader(data[pack.HEAD_SIZE:])
+		var (
+			pid   uint32
+			sysId byte
+			cmdId byte
+		)
+		pack.Read(reader, &pid, &sysId, &cmdId)
+		session.dispatch(sysId, cmdId, reader)
+	}
+}
+
+func (session *mockSession) dispatch(sysId, cmdId byte, reader *bytes.Reader) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf(nil, "mock server: handle %d %d error: %v", sysId, cmdId, err)
+		}
+	}()
+
+	if handle, ok := mockMsgHandles[msgMark(sysId, cmdId)]; ok {
+		handle(session, reader)
+	}
+}
+
+func (session *mockSession) send(sysId, cmdId byte, datas ...interface{}) {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+
+	writer := pack.NewWriter(headerData, sysId, cmdId)
+	pack.Write(writer, datas...)
+	data := writer.Bytes()
+	sealFrame(session.encrypt, data)
+	session.conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+func mockLogin(session *mockSession, reader *bytes.Reader) {
+	var (
+		serverId int
+		name     string
+		password string
+	)
+	pack.Read(reader, &serverId, &name, &password)
+
+	mockLock.Lock()
+	account, ok := mockAccounts[name]
+	if !ok {
+		account = &mockAccount{accountId: len(mockAccounts) + 1}
+		mockAccounts[name] = account
+	}
+	mockLock.Unlock()
+
+	session.account = account
+	session.send(proto.System, proto.SystemSLogin, byte(0))
+}
+
+func mockActorList(session *mockSession, reader *bytes.Reader) {
+	account := session.account
+	mockLock.Lock()
+	actors := make([]*mockActor, len(account.actors))
+	copy(actors, account.actors)
+	mockLock.Unlock()
+
+	writer := pack.NewWriter(account.accountId, len(actors))
+	for _, actor := range actors {
+		pack.Write(writer, float64(actor.actorId), actor.name, 1, actor.sex, 1, 0, 1)
+	}
+	session.send(proto.System, proto.SystemSActorLists, writer.Bytes())
+
+	//没有角色时推送随机名称
+	if len(actors) == 0 {
+		session.send(proto.System, proto.SystemSRandomName, 0, 2, fmt.Sprintf("rand%d", newMockGuid()))
+	}
+}
+
+func mockCreateActor(session *mockSession, reader *bytes.Reader) {
+	var (
+		name string
+		sex  int
+	)
+	pack.Read(reader, &name, &sex)
+
+	//重名
+	mockLock.Lock()
+	exist := name != "" && mockActorNames[name]
+	if name != "" {
+		mockActorNames[name] = true
+	}
+	mockLock.Unlock()
+	if exist {
+		session.send(proto.System, proto.SystemSCreateActor, float64(0), 1)
+		return
+	}
+
+	actor := &mockActor{actorId: int64(newMockGuid()), name: name, sex: sex}
+	if actor.name == "" {
+		actor.name = fmt.Sprintf("mock%d", actor.actorId)
+	}
+	account := session.account
+	mockLock.Lock()
+	account.actors = append(account.actors, actor)
+	mockLock.Unlock()
+	session.send(proto.System, proto.SystemSCreateActor, float64(actor.actorId), 0)
+}
+
+func mockLoginGame(session *mockSession, reader *bytes.Reader) {
+	session.send(proto.System, proto.SystemSLoginGame, 0)
+
+	//物品: 1种类型,2个物品
+	session.items[1001], session.items[1002] = 10, 5
+	session.items[1], session.items[2] = 10000, 500
+	session.send(proto.Bag, proto.BagSItemInit, int16(1), int16(1), int16(2), 1001, 10, 1002, 5)
+	session.send(proto.Bag, proto.BagSCurrencyInit, int16(2), 1, 10000, 2, 500)
+
+	heros := pack.NewWriter(int16(3))
+	for i := 1; i <= 3; i++ {
+		guid := newMockGuid()
+		session.heros[guid] = 100 + i
+		pack.Write(heros, guid, byte(1), int16(i), 0, 100+i, int16(1), 0, int16(0))
+	}
+	session.send(proto.Bag, proto.BagSHeroInit, heros.Bytes())
+
+	equips := pack.NewWriter(int16(2))
+	for i := 1; i <= 2; i++ {
+		guid := newMockGuid()
+		session.equips[guid] = 200 + i
+		pack.Write(equips, guid, 0, 200+i, 1)
+	}
+	session.send(proto.Bag, proto.BagSEquipInit, equips.Bytes())
+
+	session.send(proto.Bag, proto.BagSArtiInit,
+		int16(1), newMockGuid(), 0, 301, int16(2), 1, 2, int16(4), 0, 0, 0, 0, 1)
+	session.send(proto.Hero, proto.HeroSArmyInit, int16(0), int16(0))
+	session.send(proto.Lord, proto.LordSDecorInit, int16(1), 1, 1, int16(1), 1)
+	session.send(proto.Lord, proto.LordSEquipInit, 1, int16(2), 1, 1, 1, 1)
+}
+
+func mockEnterMainFuben(session *mockSession, reader *bytes.Reader) {
+	session.send(proto.Fight, proto.FightSResult, float64(newMockGuid()), 1)
+}
+
+func mockGetAwards(session *mockSession, reader *bytes.Reader) {
+	session.items[1] += 100
+	session.send(proto.Bag, proto.BagSAddAwards, awardSourceFight, int16(1), 1, 1, 100, session.items[1])
+}
+
+func mockOpenBox(session *mockSession, reader *bytes.Reader) {
+	var id, count int
+	pack.Read(reader, &id, &count)
+	//奖励中带上打开的物品,客户端按物品id认领应答
+	session.items[id] += count
+	session.send(proto.Bag, proto.BagSAddAwards, awardSourceOpenBox, int16(1), 1, id, count, session.items[id])
+}
+
+func mockHeroDismiss(session *mockSession, reader *bytes.Reader) {
+	msg := &HeroDismissMsg{}
+	msg.Decode(newMsgReader(reader))
+	writer := pack.NewWriter(byte(1), int16(0))
+	var count int16
+	for _, guid := range msg.Guids {
+		if _, ok := session.heros[guid]; ok {
+			delete(session.heros, guid)
+			pack.Write(writer, guid)
+			count++
+		}
+	}
+	data := writer.Bytes()
+	copy(data[1:], pack.GetBytes(count))
+	session.send(proto.Bag, proto.BagSHeroDelete, data)
+}
+
+func mockResolveEquip(session *mockSession, reader *bytes.Reader) {
+	msg := &HeroResolveEquipMsg{}
+	msg.Decode(newMsgReader(reader))
+	writer := pack.NewWriter(byte(1), int16(0))
+	var count int16
+	for _, guid := range msg.Guids {
+		if _, ok := session.equips[guid]; ok {
+			delete(session.equips, guid)
+			pack.Write(writer, guid)
+			count++
+		}
+	}
+	data := writer.Bytes()
+	copy(data[1:], pack.GetBytes(count))
+	session.send(proto.Bag, proto.BagSEquipDelete, data)
+}
+
+func mockLordRandomName(session *mockSession, reader *bytes.Reader) {
+	session.send(proto.Lord, proto.LordSRandomName, 0, fmt.Sprintf("lord%d", newMockGuid()))
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	proto "github.com/sencydai/gameworld/proto/protocol"
+)
+
+var (
+	mockTestOnce sync.Once
+	mockTestErr  error
+)
+
+// 在随机端口启动模拟服务器并把客户端配置指向它,整个测试只执行一次
+func setupMockTest(t *testing.T) {
+	mockTestOnce.Do(func() {
+		var addr string
+		if addr, mockTestErr = startMockServer("127.0.0.1:0"); mockTestErr != nil {
+			return
+		}
+		gConfigs = &GlobalConfig{
+			NamePrefix:  "e2e",
+			StartIndex:  1,
+			Scheme:      "ws",
+			Host:        addr,
+			ServerId:    1,
+			FightPeriod: 1,
+			ChatPeriod:  60,
+			MsgPeriod:   60,
+			ChatMsgs:    []string{"hi"},
+			Create:      &CreateConfig{UseRandomName: true},
+		}
+		initTargets()
+		mockTestErr = initAccountProvider()
+	})
+	if mockTestErr != nil {
+		t.Fatal(mockTestErr)
+	}
+}
+
+// 启动序号为i的客户端,等待连接建立,测试结束时关闭
+func startTestClient(t *testing.T, i int) *Account {
+	clientLock.Lock()
+	wantClients[i] = true
+	clientGens[i]++
+	gen := clientGens[i]
+	clientLock.Unlock()
+	t.Cleanup(func() { stopClient(i) })
+
+	go startClient(i, gen)
+
+	var account *Account
+	waitFor(t, func() bool {
+		accountLock.RLock()
+		defer accountLock.RUnlock()
+		account = accounts[i]
+		return account != nil
+	})
+	return account
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 5)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("wait timeout")
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+// 在账号goroutine中执行fn并等待完成,账号已关闭时返回false
+func inMailbox(account *Account, fn func()) bool {
+	done := make(chan struct{})
+	if !account.post(func() { fn(); close(done) }) {
+		return false
+	}
+	select {
+	case <-done:
+		return true
+	case <-account.done:
+		return false
+	}
+}
+
+func sentCount(sysId, cmdId byte) uint64 {
+	return atomic.LoadUint64(&sentMsgs[msgMark(sysId, cmdId)])
+}
+
+// 模拟服务器上账号name的角色
+func mockActorsOf(name string) []mockActor {
+	mockLock.Lock()
+	defer mockLock.Unlock()
+
+	actors := make([]mockActor, 0)
+	if account, ok := mockAccounts[name]; ok {
+		for _, actor := range account.actors {
+			actors = append(actors, *actor)
+		}
+	}
+	return actors
+}
+
+func TestMockServerLogin(t *testing.T) {
+	setupMockTest(t)
+	awards := sentCount(proto.Fight, proto.FightCGetAwards)
+	startTestClient(t, 1)
+
+	//FightPeriod后进入副本,收到战斗结果后领取奖励
+	waitFor(t, func() bool { return sentCount(proto.Fight, proto.FightCGetAwards) > awards })
+
+	//使用服务器推送的随机名称和性别只创建一次
+	if actors := mockActorsOf("e2e1"); len(actors) != 1 || !strings.HasPrefix(actors[0].name, "rand") || actors[0].sex != 2 {
+		t.Fatalf("mock actors %+v", actors)
+	}
+}
+
+func TestMockServerRelogin(t *testing.T) {
+	setupMockTest(t)
+	startTestClient(t, 2)
+
+	var actorId int64
+	waitFor(t, func() bool {
+		if actors := mockActorsOf("e2e2"); len(actors) > 0 {
+			actorId = actors[0].actorId
+		}
+		return actorId != 0
+	})
+	stopClient(2)
+
+	//再次登陆使用已创建的角色,不再创建
+	creates := sentCount(proto.System, proto.SystemCCreateActor)
+	logins := sentCount(proto.System, proto.SystemCLoginGame)
+	startTestClient(t, 2)
+	waitFor(t, func() bool { return sentCount(proto.System, proto.SystemCLoginGame) > logins })
+
+	if actors := mockActorsOf("e2e2"); len(actors) != 1 || actors[0].actorId != actorId {
+		t.Fatalf("mock actors %+v, want %d", actors, actorId)
+	}
+	if got := sentCount(proto.System, proto.SystemCCreateActor); got != creates {
+		t.Fatalf("create sent %d times on relogin", got-creates)
+	}
+}
@@ -0,0 +1,838 @@
+// Code generated by msggen from msg.schema. DO NOT EDIT.
+
+package main
+
+import (
+	"bytes"
+
+	"github.com/sencydai/gameworld/proto/pack"
+	proto "github.com/sencydai/gameworld/proto/protocol"
+)
+
+type BagHero struct {
+	Guid    int
+	PosType byte
+	Pos     int16
+	PosMap  int
+	Id      int
+	Level   int16
+	Exp     int
+	Stage   int16
+}
+
+func (msg *BagHero) Decode(reader *MsgReader) {
+	reader.Values(&msg.Guid, &msg.PosType, &msg.Pos, &msg.PosMap, &msg.Id, &msg.Level, &msg.Exp, &msg.Stage)
+}
+
+func (msg *BagHero) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, msg.Guid, msg.PosType, msg.Pos, msg.PosMap, msg.Id, msg.Level, msg.Exp, msg.Stage)
+}
+
+func (msg *BagHero) Remap(remap *guidRemap) {
+	msg.Guid = remap.guid(msg.Guid)
+}
+
+type BagEquip struct {
+	Guid  int
+	Pos   int
+	Id    int
+	Level int
+}
+
+func (msg *BagEquip) Decode(reader *MsgReader) {
+	reader.Values(&msg.Guid, &msg.Pos, &msg.Id, &msg.Level)
+}
+
+func (msg *BagEquip) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, msg.Guid, msg.Pos, msg.Id, msg.Level)
+}
+
+func (msg *BagEquip) Remap(remap *guidRemap) {
+	msg.Guid = remap.guid(msg.Guid)
+}
+
+type BagArti struct {
+	Guid        int
+	Pos         int
+	Id          int
+	Attrs       []int
+	StrengLevel []int
+	StrengPos   int
+}
+
+func (msg *BagArti) Decode(reader *MsgReader) {
+	reader.Values(&msg.Guid, &msg.Pos, &msg.Id)
+	msg.Attrs = make([]int, reader.Count())
+	for i := range msg.Attrs {
+		reader.Values(&msg.Attrs[i])
+	}
+	msg.StrengLevel = make([]int, reader.Count())
+	for i := range msg.StrengLevel {
+		reader.Values(&msg.StrengLevel[i])
+	}
+	reader.Values(&msg.StrengPos)
+}
+
+func (msg *BagArti) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, msg.Guid, msg.Pos, msg.Id, int16(len(msg.Attrs)))
+	for i := range msg.Attrs {
+		pack.Write(writer, msg.Attrs[i])
+	}
+	pack.Write(writer, int16(len(msg.StrengLevel)))
+	for i := range msg.StrengLevel {
+		pack.Write(writer, msg.StrengLevel[i])
+	}
+	pack.Write(writer, msg.StrengPos)
+}
+
+func (msg *BagArti) Remap(remap *guidRemap) {
+	msg.Guid = remap.guid(msg.Guid)
+}
+
+type BagHeroInitMsg struct {
+	Heros []*BagHero
+}
+
+func (msg *BagHeroInitMsg) Decode(reader *MsgReader) {
+	msg.Heros = make([]*BagHero, reader.Count())
+	for i := range msg.Heros {
+		msg.Heros[i] = &BagHero{}
+		msg.Heros[i].Decode(reader)
+	}
+}
+
+func (msg *BagHeroInitMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, int16(len(msg.Heros)))
+	for i := range msg.Heros {
+		msg.Heros[i].Encode(writer)
+	}
+}
+
+func (msg *BagHeroInitMsg) Remap(remap *guidRemap) {
+	for i := range msg.Heros {
+		msg.Heros[i].Remap(remap)
+	}
+}
+
+func BagHeroInitMsgHandler(handle func(*Account, *BagHeroInitMsg) error) ServerMsgHandler {
+	return func(account *Account, reader *MsgReader) error {
+		msg := &BagHeroInitMsg{}
+		msg.Decode(reader)
+		if err := reader.Finish(); err != nil {
+			return err
+		}
+		return handle(account, msg)
+	}
+}
+
+type BagHeroUpdateMsg struct {
+	Hero *BagHero
+}
+
+func (msg *BagHeroUpdateMsg) Decode(reader *MsgReader) {
+	msg.Hero = &BagHero{}
+	msg.Hero.Decode(reader)
+}
+
+func (msg *BagHeroUpdateMsg) Encode(writer *bytes.Buffer) {
+	msg.Hero.Encode(writer)
+}
+
+func (msg *BagHeroUpdateMsg) Remap(remap *guidRemap) {
+	msg.Hero.Remap(remap)
+}
+
+func BagHeroUpdateMsgHandler(handle func(*Account, *BagHeroUpdateMsg) error) ServerMsgHandler {
+	return func(account *Account, reader *MsgReader) error {
+		msg := &BagHeroUpdateMsg{}
+		msg.Decode(reader)
+		if err := reader.Finish(); err != nil {
+			return err
+		}
+		return handle(account, msg)
+	}
+}
+
+type BagHeroDeleteMsg struct {
+	Source byte
+	Guids  []int
+}
+
+func (msg *BagHeroDeleteMsg) Decode(reader *MsgReader) {
+	reader.Values(&msg.Source)
+	msg.Guids = make([]int, reader.Count())
+	for i := range msg.Guids {
+		reader.Values(&msg.Guids[i])
+	}
+}
+
+func (msg *BagHeroDeleteMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, msg.Source, int16(len(msg.Guids)))
+	for i := range msg.Guids {
+		pack.Write(writer, msg.Guids[i])
+	}
+}
+
+func (msg *BagHeroDeleteMsg) Remap(remap *guidRemap) {
+	for i := range msg.Guids {
+		msg.Guids[i] = remap.guid(msg.Guids[i])
+	}
+}
+
+func BagHeroDeleteMsgHandler(handle func(*Account, *BagHeroDeleteMsg) error) ServerMsgHandler {
+	return func(account *Account, reader *MsgReader) error {
+		msg := &BagHeroDeleteMsg{}
+		msg.Decode(reader)
+		if err := reader.Finish(); err != nil {
+			return err
+		}
+		return handle(account, msg)
+	}
+}
+
+type BagEquipInitMsg struct {
+	Equips []*BagEquip
+}
+
+func (msg *BagEquipInitMsg) Decode(reader *MsgReader) {
+	msg.Equips = make([]*BagEquip, reader.Count())
+	for i := range msg.Equips {
+		msg.Equips[i] = &BagEquip{}
+		msg.Equips[i].Decode(reader)
+	}
+}
+
+func (msg *BagEquipInitMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, int16(len(msg.Equips)))
+	for i := range msg.Equips {
+		msg.Equips[i].Encode(writer)
+	}
+}
+
+func (msg *BagEquipInitMsg) Remap(remap *guidRemap) {
+	for i := range msg.Equips {
+		msg.Equips[i].Remap(remap)
+	}
+}
+
+func BagEquipInitMsgHandler(handle func(*Account, *BagEquipInitMsg) error) ServerMsgHandler {
+	return func(account *Account, reader *MsgReader) error {
+		msg := &BagEquipInitMsg{}
+		msg.Decode(reader)
+		if err := reader.Finish(); err != nil {
+			return err
+		}
+		return handle(account, msg)
+	}
+}
+
+type BagEquipUpdateMsg struct {
+	Equip *BagEquip
+}
+
+func (msg *BagEquipUpdateMsg) Decode(reader *MsgReader) {
+	msg.Equip = &BagEquip{}
+	msg.Equip.Decode(reader)
+}
+
+func (msg *BagEquipUpdateMsg) Encode(writer *bytes.Buffer) {
+	msg.Equip.Encode(writer)
+}
+
+func (msg *BagEquipUpdateMsg) Remap(remap *guidRemap) {
+	msg.Equip.Remap(remap)
+}
+
+func BagEquipUpdateMsgHandler(handle func(*Account, *BagEquipUpdateMsg) error) ServerMsgHandler {
+	return func(account *Account, reader *MsgReader) error {
+		msg := &BagEquipUpdateMsg{}
+		msg.Decode(reader)
+		if err := reader.Finish(); err != nil {
+			return err
+		}
+		return handle(account, msg)
+	}
+}
+
+type BagEquipDeleteMsg struct {
+	Source byte
+	Guids  []int
+}
+
+func (msg *BagEquipDeleteMsg) Decode(reader *MsgReader) {
+	reader.Values(&msg.Source)
+	msg.Guids = make([]int, reader.Count())
+	for i := range msg.Guids {
+		reader.Values(&msg.Guids[i])
+	}
+}
+
+func (msg *BagEquipDeleteMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, msg.Source, int16(len(msg.Guids)))
+	for i := range msg.Guids {
+		pack.Write(writer, msg.Guids[i])
+	}
+}
+
+func (msg *BagEquipDeleteMsg) Remap(remap *guidRemap) {
+	for i := range msg.Guids {
+		msg.Guids[i] = remap.guid(msg.Guids[i])
+	}
+}
+
+func BagEquipDeleteMsgHandler(handle func(*Account, *BagEquipDeleteMsg) error) ServerMsgHandler {
+	return func(account *Account, reader *MsgReader) error {
+		msg := &BagEquipDeleteMsg{}
+		msg.Decode(reader)
+		if err := reader.Finish(); err != nil {
+			return err
+		}
+		return handle(account, msg)
+	}
+}
+
+type BagArtiInitMsg struct {
+	Artis []*BagArti
+}
+
+func (msg *BagArtiInitMsg) Decode(reader *MsgReader) {
+	msg.Artis = make([]*BagArti, reader.Count())
+	for i := range msg.Artis {
+		msg.Artis[i] = &BagArti{}
+		msg.Artis[i].Decode(reader)
+	}
+}
+
+func (msg *BagArtiInitMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, int16(len(msg.Artis)))
+	for i := range msg.Artis {
+		msg.Artis[i].Encode(writer)
+	}
+}
+
+func (msg *BagArtiInitMsg) Remap(remap *guidRemap) {
+	for i := range msg.Artis {
+		msg.Artis[i].Remap(remap)
+	}
+}
+
+func BagArtiInitMsgHandler(handle func(*Account, *BagArtiInitMsg) error) ServerMsgHandler {
+	return func(account *Account, reader *MsgReader) error {
+		msg := &BagArtiInitMsg{}
+		msg.Decode(reader)
+		if err := reader.Finish(); err != nil {
+			return err
+		}
+		return handle(account, msg)
+	}
+}
+
+type BagArtiUpdateMsg struct {
+	Arti *BagArti
+}
+
+func (msg *BagArtiUpdateMsg) Decode(reader *MsgReader) {
+	msg.Arti = &BagArti{}
+	msg.Arti.Decode(reader)
+}
+
+func (msg *BagArtiUpdateMsg) Encode(writer *bytes.Buffer) {
+	msg.Arti.Encode(writer)
+}
+
+func (msg *BagArtiUpdateMsg) Remap(remap *guidRemap) {
+	msg.Arti.Remap(remap)
+}
+
+func BagArtiUpdateMsgHandler(handle func(*Account, *BagArtiUpdateMsg) error) ServerMsgHandler {
+	return func(account *Account, reader *MsgReader) error {
+		msg := &BagArtiUpdateMsg{}
+		msg.Decode(reader)
+		if err := reader.Finish(); err != nil {
+			return err
+		}
+		return handle(account, msg)
+	}
+}
+
+type BagArtiDeleteMsg struct {
+	Guids []int
+}
+
+func (msg *BagArtiDeleteMsg) Decode(reader *MsgReader) {
+	msg.Guids = make([]int, reader.Count())
+	for i := range msg.Guids {
+		reader.Values(&msg.Guids[i])
+	}
+}
+
+func (msg *BagArtiDeleteMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, int16(len(msg.Guids)))
+	for i := range msg.Guids {
+		pack.Write(writer, msg.Guids[i])
+	}
+}
+
+func (msg *BagArtiDeleteMsg) Remap(remap *guidRemap) {
+	for i := range msg.Guids {
+		msg.Guids[i] = remap.guid(msg.Guids[i])
+	}
+}
+
+func BagArtiDeleteMsgHandler(handle func(*Account, *BagArtiDeleteMsg) error) ServerMsgHandler {
+	return func(account *Account, reader *MsgReader) error {
+		msg := &BagArtiDeleteMsg{}
+		msg.Decode(reader)
+		if err := reader.Finish(); err != nil {
+			return err
+		}
+		return handle(account, msg)
+	}
+}
+
+type ArmyPos struct {
+	Pos  int
+	Guid int
+}
+
+func (msg *ArmyPos) Decode(reader *MsgReader) {
+	reader.Values(&msg.Pos, &msg.Guid)
+}
+
+func (msg *ArmyPos) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, msg.Pos, msg.Guid)
+}
+
+func (msg *ArmyPos) Remap(remap *guidRemap) {
+	msg.Guid = remap.guid(msg.Guid)
+}
+
+type HeroArmyInitMsg struct {
+	Fight  []*ArmyPos
+	Assist []*ArmyPos
+}
+
+func (msg *HeroArmyInitMsg) Decode(reader *MsgReader) {
+	msg.Fight = make([]*ArmyPos, reader.Count())
+	for i := range msg.Fight {
+		msg.Fight[i] = &ArmyPos{}
+		msg.Fight[i].Decode(reader)
+	}
+	msg.Assist = make([]*ArmyPos, reader.Count())
+	for i := range msg.Assist {
+		msg.Assist[i] = &ArmyPos{}
+		msg.Assist[i].Decode(reader)
+	}
+}
+
+func (msg *HeroArmyInitMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, int16(len(msg.Fight)))
+	for i := range msg.Fight {
+		msg.Fight[i].Encode(writer)
+	}
+	pack.Write(writer, int16(len(msg.Assist)))
+	for i := range msg.Assist {
+		msg.Assist[i].Encode(writer)
+	}
+}
+
+func (msg *HeroArmyInitMsg) Remap(remap *guidRemap) {
+	for i := range msg.Fight {
+		msg.Fight[i].Remap(remap)
+	}
+	for i := range msg.Assist {
+		msg.Assist[i].Remap(remap)
+	}
+}
+
+func HeroArmyInitMsgHandler(handle func(*Account, *HeroArmyInitMsg) error) ServerMsgHandler {
+	return func(account *Account, reader *MsgReader) error {
+		msg := &HeroArmyInitMsg{}
+		msg.Decode(reader)
+		if err := reader.Finish(); err != nil {
+			return err
+		}
+		return handle(account, msg)
+	}
+}
+
+type HeroSetArmyHeroPosMsg struct {
+	Guid int
+	Army int
+	Pos  int
+}
+
+func (msg *HeroSetArmyHeroPosMsg) Decode(reader *MsgReader) {
+	reader.Values(&msg.Guid, &msg.Army, &msg.Pos)
+}
+
+func (msg *HeroSetArmyHeroPosMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, msg.Guid, msg.Army, msg.Pos)
+}
+
+func (msg *HeroSetArmyHeroPosMsg) Remap(remap *guidRemap) {
+	msg.Guid = remap.guid(msg.Guid)
+}
+
+func (msg *HeroSetArmyHeroPosMsg) Send(account *Account) {
+	writer := pack.NewWriter()
+	msg.Encode(writer)
+	account.send(proto.Hero, proto.HeroCSetArmyHeroPos, writer.Bytes())
+}
+
+type HeroOneKeyUpgradeMsg struct {
+	Guid int
+}
+
+func (msg *HeroOneKeyUpgradeMsg) Decode(reader *MsgReader) {
+	reader.Values(&msg.Guid)
+}
+
+func (msg *HeroOneKeyUpgradeMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, msg.Guid)
+}
+
+func (msg *HeroOneKeyUpgradeMsg) Remap(remap *guidRemap) {
+	msg.Guid = remap.guid(msg.Guid)
+}
+
+func (msg *HeroOneKeyUpgradeMsg) Send(account *Account) {
+	writer := pack.NewWriter()
+	msg.Encode(writer)
+	account.send(proto.Hero, proto.HeroCOneKeyUpgrade, writer.Bytes())
+}
+
+type HeroUpgradeStageMsg struct {
+	Guid int
+}
+
+func (msg *HeroUpgradeStageMsg) Decode(reader *MsgReader) {
+	reader.Values(&msg.Guid)
+}
+
+func (msg *HeroUpgradeStageMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, msg.Guid)
+}
+
+func (msg *HeroUpgradeStageMsg) Remap(remap *guidRemap) {
+	msg.Guid = remap.guid(msg.Guid)
+}
+
+func (msg *HeroUpgradeStageMsg) Send(account *Account) {
+	writer := pack.NewWriter()
+	msg.Encode(writer)
+	account.send(proto.Hero, proto.HeroCUpgradeStage, writer.Bytes())
+}
+
+type HeroWearEquipMsg struct {
+	Pos  int
+	Guid int
+	Wear int
+}
+
+func (msg *HeroWearEquipMsg) Decode(reader *MsgReader) {
+	reader.Values(&msg.Pos, &msg.Guid, &msg.Wear)
+}
+
+func (msg *HeroWearEquipMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, msg.Pos, msg.Guid, msg.Wear)
+}
+
+func (msg *HeroWearEquipMsg) Remap(remap *guidRemap) {
+	msg.Guid = remap.guid(msg.Guid)
+}
+
+func (msg *HeroWearEquipMsg) Send(account *Account) {
+	writer := pack.NewWriter()
+	msg.Encode(writer)
+	account.send(proto.Hero, proto.HeroCWearEquip, writer.Bytes())
+}
+
+type HeroStrengEquipMsg struct {
+	Guid int
+	Auto int
+}
+
+func (msg *HeroStrengEquipMsg) Decode(reader *MsgReader) {
+	reader.Values(&msg.Guid, &msg.Auto)
+}
+
+func (msg *HeroStrengEquipMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, msg.Guid, msg.Auto)
+}
+
+func (msg *HeroStrengEquipMsg) Remap(remap *guidRemap) {
+	msg.Guid = remap.guid(msg.Guid)
+}
+
+func (msg *HeroStrengEquipMsg) Send(account *Account) {
+	writer := pack.NewWriter()
+	msg.Encode(writer)
+	account.send(proto.Hero, proto.HeroCStrengEquip, writer.Bytes())
+}
+
+type HeroWearArtiMsg struct {
+	Pos  int
+	Guid int
+	Wear int
+}
+
+func (msg *HeroWearArtiMsg) Decode(reader *MsgReader) {
+	reader.Values(&msg.Pos, &msg.Guid, &msg.Wear)
+}
+
+func (msg *HeroWearArtiMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, msg.Pos, msg.Guid, msg.Wear)
+}
+
+func (msg *HeroWearArtiMsg) Remap(remap *guidRemap) {
+	msg.Guid = remap.guid(msg.Guid)
+}
+
+func (msg *HeroWearArtiMsg) Send(account *Account) {
+	writer := pack.NewWriter()
+	msg.Encode(writer)
+	account.send(proto.Hero, proto.HeroCWearArti, writer.Bytes())
+}
+
+type HeroStrengArtiMsg struct {
+	Guid int
+}
+
+func (msg *HeroStrengArtiMsg) Decode(reader *MsgReader) {
+	reader.Values(&msg.Guid)
+}
+
+func (msg *HeroStrengArtiMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, msg.Guid)
+}
+
+func (msg *HeroStrengArtiMsg) Remap(remap *guidRemap) {
+	msg.Guid = remap.guid(msg.Guid)
+}
+
+func (msg *HeroStrengArtiMsg) Send(account *Account) {
+	writer := pack.NewWriter()
+	msg.Encode(writer)
+	account.send(proto.Hero, proto.HeroCStrengArti, writer.Bytes())
+}
+
+type HeroResolveEquipMsg struct {
+	Guids []int
+}
+
+func (msg *HeroResolveEquipMsg) Decode(reader *MsgReader) {
+	msg.Guids = make([]int, reader.Count())
+	for i := range msg.Guids {
+		reader.Values(&msg.Guids[i])
+	}
+}
+
+func (msg *HeroResolveEquipMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, int16(len(msg.Guids)))
+	for i := range msg.Guids {
+		pack.Write(writer, msg.Guids[i])
+	}
+}
+
+func (msg *HeroResolveEquipMsg) Remap(remap *guidRemap) {
+	for i := range msg.Guids {
+		msg.Guids[i] = remap.guid(msg.Guids[i])
+	}
+}
+
+func (msg *HeroResolveEquipMsg) Send(account *Account) {
+	writer := pack.NewWriter()
+	msg.Encode(writer)
+	account.send(proto.Hero, proto.HeroCResolveEquip, writer.Bytes())
+}
+
+type HeroRecastEquipMsg struct {
+	Guids []int
+}
+
+func (msg *HeroRecastEquipMsg) Decode(reader *MsgReader) {
+	msg.Guids = make([]int, reader.Count())
+	for i := range msg.Guids {
+		reader.Values(&msg.Guids[i])
+	}
+}
+
+func (msg *HeroRecastEquipMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, int16(len(msg.Guids)))
+	for i := range msg.Guids {
+		pack.Write(writer, msg.Guids[i])
+	}
+}
+
+func (msg *HeroRecastEquipMsg) Remap(remap *guidRemap) {
+	for i := range msg.Guids {
+		msg.Guids[i] = remap.guid(msg.Guids[i])
+	}
+}
+
+func (msg *HeroRecastEquipMsg) Send(account *Account) {
+	writer := pack.NewWriter()
+	msg.Encode(writer)
+	account.send(proto.Hero, proto.HeroCRecastEquip, writer.Bytes())
+}
+
+type HeroDismissMsg struct {
+	Guids []int
+}
+
+func (msg *HeroDismissMsg) Decode(reader *MsgReader) {
+	msg.Guids = make([]int, reader.Count())
+	for i := range msg.Guids {
+		reader.Values(&msg.Guids[i])
+	}
+}
+
+func (msg *HeroDismissMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, int16(len(msg.Guids)))
+	for i := range msg.Guids {
+		pack.Write(writer, msg.Guids[i])
+	}
+}
+
+func (msg *HeroDismissMsg) Remap(remap *guidRemap) {
+	for i := range msg.Guids {
+		msg.Guids[i] = remap.guid(msg.Guids[i])
+	}
+}
+
+func (msg *HeroDismissMsg) Send(account *Account) {
+	writer := pack.NewWriter()
+	msg.Encode(writer)
+	account.send(proto.Hero, proto.HeroCHeroDismiss, writer.Bytes())
+}
+
+type HeroRebuildMsg struct {
+	Guids []int
+}
+
+func (msg *HeroRebuildMsg) Decode(reader *MsgReader) {
+	msg.Guids = make([]int, reader.Count())
+	for i := range msg.Guids {
+		reader.Values(&msg.Guids[i])
+	}
+}
+
+func (msg *HeroRebuildMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, int16(len(msg.Guids)))
+	for i := range msg.Guids {
+		pack.Write(writer, msg.Guids[i])
+	}
+}
+
+func (msg *HeroRebuildMsg) Remap(remap *guidRemap) {
+	for i := range msg.Guids {
+		msg.Guids[i] = remap.guid(msg.Guids[i])
+	}
+}
+
+func (msg *HeroRebuildMsg) Send(account *Account) {
+	writer := pack.NewWriter()
+	msg.Encode(writer)
+	account.send(proto.Hero, proto.HeroCHeroRebuild, writer.Bytes())
+}
+
+type HeroResolveArtiMsg struct {
+	Guids []int
+}
+
+func (msg *HeroResolveArtiMsg) Decode(reader *MsgReader) {
+	msg.Guids = make([]int, reader.Count())
+	for i := range msg.Guids {
+		reader.Values(&msg.Guids[i])
+	}
+}
+
+func (msg *HeroResolveArtiMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, int16(len(msg.Guids)))
+	for i := range msg.Guids {
+		pack.Write(writer, msg.Guids[i])
+	}
+}
+
+func (msg *HeroResolveArtiMsg) Remap(remap *guidRemap) {
+	for i := range msg.Guids {
+		msg.Guids[i] = remap.guid(msg.Guids[i])
+	}
+}
+
+func (msg *HeroResolveArtiMsg) Send(account *Account) {
+	writer := pack.NewWriter()
+	msg.Encode(writer)
+	account.send(proto.Hero, proto.HeroCResolveArti, writer.Bytes())
+}
+
+type LordLookupLordMsg struct {
+	Flag     int
+	Name     string
+	ServerId int
+	ActorId  float64
+	Extra    string
+}
+
+func (msg *LordLookupLordMsg) Decode(reader *MsgReader) {
+	reader.Values(&msg.Flag, &msg.Name, &msg.ServerId, &msg.ActorId, &msg.Extra)
+}
+
+func (msg *LordLookupLordMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, msg.Flag, msg.Name, msg.ServerId, msg.ActorId, msg.Extra)
+}
+
+func (msg *LordLookupLordMsg) Remap(remap *guidRemap) {
+	msg.ActorId = remap.actor(msg.ActorId)
+}
+
+func (msg *LordLookupLordMsg) Send(account *Account) {
+	writer := pack.NewWriter()
+	msg.Encode(writer)
+	account.send(proto.Lord, proto.LordCLookupLord, writer.Bytes())
+}
+
+type LordLookupHeroMsg struct {
+	Flag     int
+	Name     string
+	ServerId int
+	ActorId  float64
+	HeroPos  int
+}
+
+func (msg *LordLookupHeroMsg) Decode(reader *MsgReader) {
+	reader.Values(&msg.Flag, &msg.Name, &msg.ServerId, &msg.ActorId, &msg.HeroPos)
+}
+
+func (msg *LordLookupHeroMsg) Encode(writer *bytes.Buffer) {
+	pack.Write(writer, msg.Flag, msg.Name, msg.ServerId, msg.ActorId, msg.HeroPos)
+}
+
+func (msg *LordLookupHeroMsg) Remap(remap *guidRemap) {
+	msg.ActorId = remap.actor(msg.ActorId)
+}
+
+func (msg *LordLookupHeroMsg) Send(account *Account) {
+	writer := pack.NewWriter()
+	msg.Encode(writer)
+	account.send(proto.Lord, proto.LordCLookupHero, writer.Bytes())
+}
+
+// 客户端消息,key为msgMark
+var clientMsgs = map[int]func() msgCodec{
+	msgMark(proto.Hero, proto.HeroCSetArmyHeroPos): func() msgCodec { return &HeroSetArmyHeroPosMsg{} },
+	msgMark(proto.Hero, proto.HeroCOneKeyUpgrade):  func() msgCodec { return &HeroOneKeyUpgradeMsg{} },
+	msgMark(proto.Hero, proto.HeroCUpgradeStage):   func() msgCodec { return &HeroUpgradeStageMsg{} },
+	msgMark(proto.Hero, proto.HeroCWearEquip):      func() msgCodec { return &HeroWearEquipMsg{} },
+	msgMark(proto.Hero, proto.HeroCStrengEquip):    func() msgCodec { return &HeroStrengEquipMsg{} },
+	msgMark(proto.Hero, proto.HeroCWearArti):       func() msgCodec { return &HeroWearArtiMsg{} },
+	msgMark(proto.Hero, proto.HeroCStrengArti):     func() msgCodec { return &HeroStrengArtiMsg{} },
+	msgMark(proto.Hero, proto.HeroCResolveEquip):   func() msgCodec { return &HeroResolveEquipMsg{} },
+	msgMark(proto.Hero, proto.HeroCRecastEquip):    func() msgCodec { return &HeroRecastEquipMsg{} },
+	msgMark(proto.Hero, proto.HeroCHeroDismiss):    func() msgCodec { return &HeroDismissMsg{} },
+	msgMark(proto.Hero, proto.HeroCHeroRebuild):    func() msgCodec { return &HeroRebuildMsg{} },
+	msgMark(proto.Hero, proto.HeroCResolveArti):    func() msgCodec { return &HeroResolveArtiMsg{} },
+	msgMark(proto.Lord, proto.LordCLookupLord):     func() msgCodec { return &LordLookupLordMsg{} },
+	msgMark(proto.Lord, proto.LordCLookupHero):     func() msgCodec { return &LordLookupHeroMsg{} },
+}
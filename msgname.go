@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+)
+
+//go:generate go run ./cmd/protoname -pkg github.com/sencydai/gameworld/proto/protocol -out msgname_gen.go
+
+var (
+	sysNames = make(map[byte]string)
+	msgNames = make(map[int]string)
+)
+
+func RegSysName(sysId byte, name string) {
+	sysNames[sysId] = name
+}
+
+func RegMsgName(sysId, cmdId byte, name string) {
+	msgNames[msgMark(sysId, cmdId)] = name
+}
+
+func sysName(sysId byte) string {
+	if name, ok := sysNames[sysId]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", sysId)
+}
+
+// 协议常量名,未登记时为 系统名-cmdId
+func markName(mark int) string {
+	if name, ok := msgNames[mark]; ok {
+		return name
+	}
+	return fmt.Sprintf("%s-%d", sysName(byte(mark>>8)), mark&0xff)
+}
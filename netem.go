@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	//丢包重传的默认附加延迟(毫秒)
+	defaultLossDelay = 200
+	//每个方向排队等待投递的数据块上限
+	netQueueSize = 1024
+	netReadSize  = 32 * 1024
+	//入方向已从socket读出但未被消费的字节上限,相当于接收窗口
+	//消费变慢时不再读取socket,服务器的发送随之阻塞
+	netReadWindow = 64 * 1024
+)
+
+type NetProfile struct {
+	Name string
+	//账号比例
+	Ratio int
+	//单向延迟及抖动(毫秒)
+	Latency int
+	Jitter  int
+	//带宽(字节/秒),0不限制
+	Bandwidth int
+	//每StallEvery秒卡顿StallFor秒
+	StallEvery int
+	StallFor   int
+	//丢包率(百分比),丢包的数据块按重传处理,附加LossDelay毫秒延迟
+	Loss      int
+	LossDelay int
+}
+
+type netProfileKey struct{}
+
+// 单方向的投递计划,数据块按 到达时间+延迟 投递,先到先投,不阻塞后续数据
+type netSchedule struct {
+	profile *NetProfile
+	start   time.Time
+	lock    sync.Mutex
+	//带宽占用结束时间
+	busy time.Time
+	//上一块的投递时间
+	last time.Time
+}
+
+func newNetSchedule(profile *NetProfile) *netSchedule {
+	return &netSchedule{profile: profile, start: time.Now()}
+}
+
+// 计算当前到达的n字节数据块的投递时间
+func (schedule *netSchedule) deliverAt(n int) time.Time {
+	profile := schedule.profile
+	schedule.lock.Lock()
+	defer schedule.lock.Unlock()
+
+	at := time.Now()
+	if bandwidth := profile.Bandwidth; bandwidth > 0 {
+		if schedule.busy.After(at) {
+			at = schedule.busy
+		}
+		at = at.Add(time.Duration(n) * time.Second / time.Duration(bandwidth))
+		schedule.busy = at
+	}
+
+	latency := profile.Latency
+	if profile.Jitter > 0 {
+		latency += rand.Intn(profile.Jitter*2+1) - profile.Jitter
+	}
+	if profile.Loss > 0 && rand.Intn(100) < profile.Loss {
+		lossDelay := profile.LossDelay
+		if lossDelay <= 0 {
+			lossDelay = defaultLossDelay
+		}
+		latency += lossDelay
+	}
+	if latency > 0 {
+		at = at.Add(time.Millisecond * time.Duration(latency))
+	}
+
+	at = schedule.afterStall(at)
+	if at.Before(schedule.last) {
+		at = schedule.last
+	}
+	schedule.last = at
+	return at
+}
+
+// 落在卡顿区间内的投递推迟到卡顿结束
+func (schedule *netSchedule) afterStall(at time.Time) time.Time {
+	profile := schedule.profile
+	if profile.StallEvery <= 0 || profile.StallFor <= 0 {
+		return at
+	}
+	period := time.Second * time.Duration(profile.StallEvery+profile.StallFor)
+	phase := at.Sub(schedule.start) % period
+	if running := time.Second * time.Duration(profile.StallEvery); phase >= running {
+		return at.Add(period - phase)
+	}
+	return at
+}
+
+type netChunk struct {
+	data []byte
+	at   time.Time
+	//读方向上游错误,在之前的数据之后返回
+	err error
+}
+
+// 按网络配置注入延迟、限速、丢包和卡顿
+// 读写各有一个定时队列,由独立goroutine收发,延迟不影响吞吐
+// 入方向受netReadWindow限制,消费慢时对端的写会阻塞
+type emuConn struct {
+	net.Conn
+	in     *netSchedule
+	out    *netSchedule
+	reads  chan *netChunk
+	writes chan *netChunk
+	closed chan struct{}
+	once   sync.Once
+
+	//只在Read中使用
+	head    *netChunk
+	readErr error
+	//入方向窗口有空余时通知readLoop
+	space chan struct{}
+
+	lock          sync.Mutex
+	queued        int
+	readDeadline  time.Time
+	writeDeadline time.Time
+	//截止时间修改时关闭并重建,唤醒等待中的读写
+	deadlineC chan struct{}
+	writeErr  error
+}
+
+var (
+	netProfileRatio int
+)
+
+func initNetProfiles() {
+	for _, profile := range gConfigs.NetProfiles {
+		if profile.Ratio > 0 {
+			netProfileRatio += profile.Ratio
+		}
+	}
+}
+
+// 按客户端序号分配网络配置
+func netProfileFor(i int) *NetProfile {
+	if netProfileRatio == 0 {
+		return nil
+	}
+	slot := i % netProfileRatio
+	for _, profile := range gConfigs.NetProfiles {
+		if profile.Ratio <= 0 {
+			continue
+		}
+		if slot < profile.Ratio {
+			return profile
+		}
+		slot -= profile.Ratio
+	}
+	return nil
+}
+
+func withNetProfile(ctx context.Context, profile *NetProfile) context.Context {
+	if profile == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, netProfileKey{}, profile)
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func emulatedDial(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if profile, ok := ctx.Value(netProfileKey{}).(*NetProfile); ok {
+			return newEmuConn(conn, profile), nil
+		}
+		return conn, nil
+	}
+}
+
+func newEmuConn(conn net.Conn, profile *NetProfile) *emuConn {
+	emu := &emuConn{
+		Conn:      conn,
+		in:        newNetSchedule(profile),
+		out:       newNetSchedule(profile),
+		reads:     make(chan *netChunk, netQueueSize),
+		writes:    make(chan *netChunk, netQueueSize),
+		closed:    make(chan struct{}),
+		space:     make(chan struct{}, 1),
+		deadlineC: make(chan struct{}),
+	}
+	go emu.readLoop()
+	go emu.writeLoop()
+	return emu
+}
+
+func (conn *emuConn) readLoop() {
+	buff := make([]byte, netReadSize)
+	for {
+		size := conn.reserve()
+		if size == 0 {
+			return
+		}
+		if size > len(buff) {
+			size = len(buff)
+		}
+		n, err := conn.Conn.Read(buff[:size])
+		//按实际长度复制,读缓冲重复使用
+		chunk := &netChunk{data: append([]byte(nil), buff[:n]...), at: conn.in.deliverAt(n), err: err}
+		conn.lock.Lock()
+		conn.queued += n
+		conn.lock.Unlock()
+		select {
+		case conn.reads <- chunk:
+		case <-conn.closed:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// 等待入方向窗口有空余,返回本次最多读取的字节数,连接关闭时返回0
+func (conn *emuConn) reserve() int {
+	for {
+		conn.lock.Lock()
+		free := netReadWindow - conn.queued
+		conn.lock.Unlock()
+		if free > 0 {
+			return free
+		}
+		select {
+		case <-conn.space:
+		case <-conn.closed:
+			return 0
+		}
+	}
+}
+
+// Read消费n字节后归还窗口
+func (conn *emuConn) release(n int) {
+	conn.lock.Lock()
+	conn.queued -= n
+	conn.lock.Unlock()
+	select {
+	case conn.space <- struct{}{}:
+	default:
+	}
+}
+
+func (conn *emuConn) writeLoop() {
+	for {
+		select {
+		case chunk := <-conn.writes:
+			if d := time.Until(chunk.at); d > 0 {
+				t := time.NewTimer(d)
+				select {
+				case <-t.C:
+				case <-conn.closed:
+					t.Stop()
+					return
+				}
+			}
+			if _, err := conn.Conn.Write(chunk.data); err != nil {
+				conn.lock.Lock()
+				conn.writeErr = err
+				conn.lock.Unlock()
+				conn.Close()
+				return
+			}
+		case <-conn.closed:
+			return
+		}
+	}
+}
+
+func (conn *emuConn) deadline(read bool) (time.Time, chan struct{}) {
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+
+	if read {
+		return conn.readDeadline, conn.deadlineC
+	}
+	return conn.writeDeadline, conn.deadlineC
+}
+
+// 等待到at、收到recv或发送send完成,截止时间先到达时返回超时
+func (conn *emuConn) wait(read bool, at time.Time, recv <-chan *netChunk, send chan<- *netChunk, chunk *netChunk) (*netChunk, error) {
+	var due <-chan time.Time
+	if recv == nil && send == nil {
+		d := time.Until(at)
+		if d <= 0 {
+			return nil, nil
+		}
+		t := time.NewTimer(d)
+		defer t.Stop()
+		due = t.C
+	}
+
+	for {
+		deadline, changed := conn.deadline(read)
+		var expired <-chan time.Time
+		var t *time.Timer
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return nil, os.ErrDeadlineExceeded
+			}
+			t = time.NewTimer(d)
+			expired = t.C
+		}
+
+		var (
+			got *netChunk
+			err error
+			ok  = true
+		)
+		select {
+		case got = <-recv:
+		case send <- chunk:
+		case <-due:
+		case <-expired:
+			err = os.ErrDeadlineExceeded
+		case <-conn.closed:
+			err = net.ErrClosed
+		case <-changed:
+			ok = false
+		}
+		if t != nil {
+			t.Stop()
+		}
+		if ok {
+			return got, err
+		}
+	}
+}
+
+func (conn *emuConn) Read(b []byte) (int, error) {
+	if conn.head == nil {
+		if conn.readErr != nil {
+			return 0, conn.readErr
+		}
+		chunk, err := conn.wait(true, time.Time{}, conn.reads, nil, nil)
+		if err != nil {
+			return 0, err
+		}
+		conn.head = chunk
+	}
+	if _, err := conn.wait(true, conn.head.at, nil, nil, nil); err != nil {
+		return 0, err
+	}
+
+	chunk := conn.head
+	n := copy(b, chunk.data)
+	chunk.data = chunk.data[n:]
+	conn.release(n)
+	if len(chunk.data) > 0 {
+		return n, nil
+	}
+	conn.head = nil
+	if chunk.err != nil {
+		conn.readErr = chunk.err
+		if n == 0 {
+			return 0, chunk.err
+		}
+	}
+	return n, nil
+}
+
+// 数据进入发送队列即返回,队列满时阻塞
+func (conn *emuConn) Write(b []byte) (int, error) {
+	conn.lock.Lock()
+	err := conn.writeErr
+	conn.lock.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	chunk := &netChunk{data: append([]byte(nil), b...), at: conn.out.deliverAt(len(b))}
+	if _, err = conn.wait(false, time.Time{}, nil, conn.writes, chunk); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (conn *emuConn) Close() error {
+	conn.once.Do(func() {
+		close(conn.closed)
+	})
+	return conn.Conn.Close()
+}
+
+// 截止时间由emuConn自己处理,不传给底层连接,避免打断收发goroutine
+func (conn *emuConn) setDeadline(read, write bool, t time.Time) error {
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+
+	if read {
+		conn.readDeadline = t
+	}
+	if write {
+		conn.writeDeadline = t
+	}
+	close(conn.deadlineC)
+	conn.deadlineC = make(chan struct{})
+	return nil
+}
+
+func (conn *emuConn) SetDeadline(t time.Time) error {
+	return conn.setDeadline(true, true, t)
+}
+
+func (conn *emuConn) SetReadDeadline(t time.Time) error {
+	return conn.setDeadline(true, false, t)
+}
+
+func (conn *emuConn) SetWriteDeadline(t time.Time) error {
+	return conn.setDeadline(false, true, t)
+}
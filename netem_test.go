@@ -0,0 +1,213 @@
+package main
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestEmuConnReadLatencyKeepsThroughput(t *testing.T) {
+	client, server := net.Pipe()
+	conn := newEmuConn(client, &NetProfile{Latency: 100})
+	defer conn.Close()
+	defer server.Close()
+
+	go func() {
+		for i := 0; i < 20; i++ {
+			server.Write([]byte{byte(i)})
+			time.Sleep(time.Millisecond * 5)
+		}
+	}()
+
+	start := time.Now()
+	buff := make([]byte, 1)
+	for i := 0; i < 20; i++ {
+		if _, err := io.ReadFull(conn, buff); err != nil || buff[0] != byte(i) {
+			t.Fatalf("read %d: %v %v", i, buff, err)
+		}
+		if i == 0 && time.Since(start) < time.Millisecond*90 {
+			t.Fatalf("first chunk after %v", time.Since(start))
+		}
+	}
+	//每块单独等待延迟时需要2秒
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("20 chunks took %v", elapsed)
+	}
+}
+
+func TestEmuConnWriteQueued(t *testing.T) {
+	client, server := net.Pipe()
+	conn := newEmuConn(client, &NetProfile{Latency: 100})
+	defer conn.Close()
+	defer server.Close()
+
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		if n, err := conn.Write([]byte{byte(i)}); n != 1 || err != nil {
+			t.Fatalf("write %d: %d %v", i, n, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Millisecond*50 {
+		t.Fatalf("writes blocked %v", elapsed)
+	}
+
+	buff := make([]byte, 20)
+	if _, err := io.ReadFull(server, buff); err != nil {
+		t.Fatal(err)
+	}
+	for i, b := range buff {
+		if b != byte(i) {
+			t.Fatalf("out of order %v", buff)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < time.Millisecond*90 || elapsed > time.Second {
+		t.Fatalf("delivered after %v", elapsed)
+	}
+}
+
+func TestEmuConnReadDeadline(t *testing.T) {
+	client, server := net.Pipe()
+	conn := newEmuConn(client, &NetProfile{Latency: 10})
+	defer conn.Close()
+	defer server.Close()
+
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond * 50))
+	buff := make([]byte, 1)
+	_, err := conn.Read(buff)
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Fatalf("read error %v", err)
+	}
+
+	//阻塞中的Read在截止时间修改后返回
+	result := make(chan error, 1)
+	conn.SetReadDeadline(time.Time{})
+	go func() {
+		_, err := conn.Read(buff)
+		result <- err
+	}()
+	time.Sleep(time.Millisecond * 20)
+	conn.SetReadDeadline(time.Now())
+	select {
+	case err = <-result:
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+			t.Fatalf("read error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("read not woken by deadline")
+	}
+
+	//超时后连接仍可读
+	conn.SetReadDeadline(time.Time{})
+	go server.Write([]byte{7})
+	if _, err = conn.Read(buff); err != nil || buff[0] != 7 {
+		t.Fatalf("read %v %v", buff, err)
+	}
+}
+
+func TestEmuConnClose(t *testing.T) {
+	client, server := net.Pipe()
+	conn := newEmuConn(client, &NetProfile{})
+	defer server.Close()
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		result <- err
+	}()
+	conn.Close()
+	select {
+	case err := <-result:
+		if err == nil {
+			t.Fatal("read after close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("read not woken by close")
+	}
+}
+
+func TestNetScheduleBandwidth(t *testing.T) {
+	schedule := newNetSchedule(&NetProfile{Bandwidth: 1000})
+	start := time.Now()
+	var at time.Time
+	for i := 0; i < 3; i++ {
+		at = schedule.deliverAt(100)
+	}
+	//3*100字节按1000字节/秒依次发送
+	if d := at.Sub(start); d < time.Millisecond*300 || d > time.Millisecond*350 {
+		t.Fatalf("deliver after %v", d)
+	}
+}
+
+func TestNetScheduleLoss(t *testing.T) {
+	schedule := newNetSchedule(&NetProfile{Loss: 100, LossDelay: 100})
+	if d := time.Until(schedule.deliverAt(1)); d < time.Millisecond*90 || d > time.Millisecond*150 {
+		t.Fatalf("loss delay %v", d)
+	}
+	schedule = newNetSchedule(&NetProfile{Loss: 100})
+	if d := time.Until(schedule.deliverAt(1)); d < time.Millisecond*190 {
+		t.Fatalf("default loss delay %v", d)
+	}
+}
+
+func TestNetScheduleStallAndOrder(t *testing.T) {
+	schedule := newNetSchedule(&NetProfile{StallEvery: 1, StallFor: 1})
+	//处于第1.5秒,卡顿到第2秒结束
+	schedule.start = time.Now().Add(-time.Millisecond * 1500)
+	if d := time.Until(schedule.deliverAt(1)); d < time.Millisecond*400 || d > time.Millisecond*600 {
+		t.Fatalf("stall delay %v", d)
+	}
+
+	schedule = newNetSchedule(&NetProfile{Latency: 50, Jitter: 50})
+	var last time.Time
+	for i := 0; i < 100; i++ {
+		at := schedule.deliverAt(1)
+		if at.Before(last) {
+			t.Fatal("delivery out of order")
+		}
+		last = at
+	}
+}
+
+func TestEmuConnSlowReaderBlocksPeer(t *testing.T) {
+	client, server := net.Pipe()
+	conn := newEmuConn(client, &NetProfile{Latency: 10})
+	defer conn.Close()
+	defer server.Close()
+
+	//net.Pipe没有缓冲,对端Write在数据全部被读出前阻塞
+	data := make([]byte, netReadWindow*4)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	written := make(chan error, 1)
+	go func() {
+		_, err := server.Write(data)
+		written <- err
+	}()
+
+	select {
+	case err := <-written:
+		t.Fatalf("peer write finished without reader: %v", err)
+	case <-time.After(time.Millisecond * 100):
+	}
+	conn.lock.Lock()
+	queued := conn.queued
+	conn.lock.Unlock()
+	if queued != netReadWindow {
+		t.Fatalf("queued %d, want %d", queued, netReadWindow)
+	}
+
+	buff := make([]byte, len(data))
+	if _, err := io.ReadFull(conn, buff); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+	for i := range buff {
+		if buff[i] != data[i] {
+			t.Fatalf("byte %d: %d, want %d", i, buff[i], data[i])
+		}
+	}
+}
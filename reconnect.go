@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	//未配置Initial时的首次重连延迟(秒),避免0延迟反复重连
+	defaultReconnectInitial = 1
+)
+
+type ReconnectPolicy struct {
+	//不重连
+	Never bool
+	//首次重连延迟(秒),<=0时为1
+	Initial float64
+	//延迟倍率
+	Multiplier float64
+	//最大延迟(秒)
+	Max float64
+	//随机抖动比例,0.2表示±20%
+	Jitter float64
+	//最大连续重连次数,0不限制
+	MaxAttempts int
+}
+
+var (
+	//连续重连次数,登陆成功后清零
+	reconnectAttempts = make(map[int]int)
+	reconnectLock     sync.Mutex
+)
+
+func resetReconnect(i int) {
+	reconnectLock.Lock()
+	defer reconnectLock.Unlock()
+
+	delete(reconnectAttempts, i)
+}
+
+func nextReconnectDelay(i int, dialFailed bool) (time.Duration, bool) {
+	policy := gConfigs.Reconnect
+	if policy == nil {
+		if dialFailed {
+			return time.Second * 15, true
+		}
+		return time.Second * 300, true
+	}
+	if policy.Never {
+		return 0, false
+	}
+
+	reconnectLock.Lock()
+	reconnectAttempts[i]++
+	attempts := reconnectAttempts[i]
+	reconnectLock.Unlock()
+	if policy.MaxAttempts > 0 && attempts > policy.MaxAttempts {
+		return 0, false
+	}
+
+	return policy.delay(attempts), true
+}
+
+// 第attempts次重连的延迟
+func (policy *ReconnectPolicy) delay(attempts int) time.Duration {
+	initial := policy.Initial
+	if initial <= 0 {
+		initial = defaultReconnectInitial
+	}
+	multiplier := math.Max(policy.Multiplier, 1)
+	delay := initial * math.Pow(multiplier, float64(attempts-1))
+	if policy.Max > 0 && delay > policy.Max {
+		delay = policy.Max
+	}
+	if policy.Jitter > 0 {
+		delay *= 1 + policy.Jitter*(rand.Float64()*2-1)
+	}
+	return time.Duration(delay * float64(time.Second))
+}
+
+// gen为断开连接所属的代数,序号已停止或被重新加入时不再重连
+func scheduleReconnect(i, gen int, dialFailed bool) {
+	if !clientWanted(i, gen) {
+		return
+	}
+	delay, ok := nextReconnectDelay(i, dialFailed)
+	if !ok {
+		log.Printf(nil, "client %d give up reconnect", i)
+		clientLock.Lock()
+		if clientGens[i] == gen {
+			delete(wantClients, i)
+		}
+		clientLock.Unlock()
+		return
+	}
+
+	restartClient(i, gen, delay)
+}
+
+func restartClient(i, gen int, delay time.Duration) {
+	if !clientWanted(i, gen) {
+		return
+	}
+	countReconnect()
+	AfterDelay(nil, fmt.Sprintf("startClient_%d", i), delay, startClient, i, gen)
+}
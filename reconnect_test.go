@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestReconnectDelayDefaultInitial(t *testing.T) {
+	policy := &ReconnectPolicy{Multiplier: 2, Max: 3}
+	if d := policy.delay(1); d != time.Second {
+		t.Fatalf("first delay %v", d)
+	}
+	if d := policy.delay(2); d != time.Second*2 {
+		t.Fatalf("second delay %v", d)
+	}
+	if d := policy.delay(5); d != time.Second*3 {
+		t.Fatalf("capped delay %v", d)
+	}
+}
+
+func TestReconnectDelayJitter(t *testing.T) {
+	policy := &ReconnectPolicy{Initial: 10, Jitter: 0.2}
+	for i := 0; i < 20; i++ {
+		if d := policy.delay(1); d < time.Second*8 || d > time.Second*12 {
+			t.Fatalf("delay %v", d)
+		}
+	}
+}
+
+func TestScheduleReconnectStaleGen(t *testing.T) {
+	const i = 1000
+	clientLock.Lock()
+	wantClients[i] = true
+	clientGens[i] += 2
+	gen := clientGens[i]
+	clientLock.Unlock()
+	defer stopClient(i)
+
+	//序号被重新加入后,旧连接断开不能再拉起客户端
+	scheduleReconnect(i, gen-1, false)
+	if !IsStoped(nil, fmt.Sprintf("startClient_%d", i)) {
+		t.Fatal("stale generation scheduled reconnect")
+	}
+	if !clientWanted(i, gen) {
+		t.Fatal("stale generation removed client")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	dirOut = "out"
+	dirIn  = "in"
+)
+
+type CaptureRecord struct {
+	Time    int64  `json:"t"`
+	Dir     string `json:"dir"`
+	Account string `json:"account"`
+	Index   int    `json:"index"`
+	Pid     uint32 `json:"pid,omitempty"`
+	SysId   byte   `json:"sys"`
+	CmdId   byte   `json:"cmd"`
+	Payload []byte `json:"payload"`
+}
+
+type recorder struct {
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *json.Encoder
+	lock    sync.Mutex
+}
+
+var (
+	capture *recorder
+)
+
+func openRecorder(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriterSize(file, 1024*64)
+	capture = &recorder{file: file, writer: writer, encoder: json.NewEncoder(writer)}
+
+	go func() {
+		for {
+			select {
+			case <-time.After(time.Second):
+				capture.sync()
+			}
+		}
+	}()
+	return nil
+}
+
+func (r *recorder) sync() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.writer.Flush()
+}
+
+func (r *recorder) Close() {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.writer.Flush()
+	r.file.Close()
+}
+
+func (r *recorder) Record(account *Account, dir string, pid uint32, sysId, cmdId byte, payload []byte) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.encoder.Encode(&CaptureRecord{
+		Time:    time.Now().UnixNano(),
+		Dir:     dir,
+		Account: account.accountName,
+		Index:   account.index,
+		Pid:     pid,
+		SysId:   sysId,
+		CmdId:   cmdId,
+		Payload: payload,
+	})
+}
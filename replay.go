@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/sencydai/gameworld/proto/pack"
+	proto "github.com/sencydai/gameworld/proto/protocol"
+)
+
+type ReplaySession struct {
+	index      int
+	name       string
+	start      int64
+	oldActorId int64
+	records    []*CaptureRecord
+	//录制时的背包数据,用于映射guid
+	old *Account
+}
+
+var (
+	replaySessions = make(map[int]*ReplaySession)
+)
+
+// 重放时自动应答的消息也在抓包中,由重放发送
+func isReplaying(account *Account) bool {
+	_, ok := replaySessions[account.index]
+	return ok
+}
+
+func loadReplay(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*64), 1024*1024*16)
+	for scanner.Scan() {
+		record := &CaptureRecord{}
+		if err = json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return err
+		}
+		session, ok := replaySessions[record.Index]
+		if !ok {
+			session = &ReplaySession{
+				index: record.Index,
+				name:  record.Account,
+				old:   &Account{accountName: record.Account, data: make(map[string]interface{})},
+			}
+			replaySessions[record.Index] = session
+		}
+		if err = session.add(record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (session *ReplaySession) add(record *CaptureRecord) error {
+	reader := bytes.NewReader(record.Payload)
+	if record.Dir == dirIn {
+		var handle ServerMsgHandler
+		switch msgMark(record.SysId, record.CmdId) {
+		case msgMark(proto.Bag, proto.BagSHeroInit):
+			handle = BagHeroInitMsgHandler(HandleBagHeroInit)
+		case msgMark(proto.Bag, proto.BagSEquipInit):
+			handle = BagEquipInitMsgHandler(HandleBagEquipInit)
+		case msgMark(proto.Bag, proto.BagSArtiInit):
+			handle = BagArtiInitMsgHandler(HandleBagArtiInit)
+		}
+		if handle != nil {
+			if err := handle(session.old, newMsgReader(reader)); err != nil {
+				return fmt.Errorf("replay %s %s: %w", session.name, markName(msgMark(record.SysId, record.CmdId)), err)
+			}
+		}
+		return nil
+	}
+
+	//登陆流程由正常的消息处理驱动,只重放进入游戏之后的消息
+	if record.SysId == proto.System {
+		if record.CmdId == proto.SystemCLoginGame {
+			var actorId float64
+			pack.Read(reader, &actorId)
+			session.oldActorId = int64(actorId)
+			session.start = record.Time
+			session.records = session.records[:0]
+		}
+		return nil
+	}
+	if session.start == 0 {
+		session.start = record.Time
+	}
+	session.records = append(session.records, record)
+	return nil
+}
+
+// 录制会话到当前会话的guid/actorId映射
+type guidRemap struct {
+	guids    map[int]int
+	oldActor float64
+	newActor float64
+}
+
+func (remap *guidRemap) guid(guid int) int {
+	if value, ok := remap.guids[guid]; ok {
+		return value
+	}
+	return guid
+}
+
+func (remap *guidRemap) actor(actorId float64) float64 {
+	if remap.oldActor != 0 && actorId == remap.oldActor {
+		return remap.newActor
+	}
+	return actorId
+}
+
+func pairGuids(guids map[int]int, old, cur map[int]int) {
+	oldIds := make(map[int][]int)
+	for guid, id := range old {
+		oldIds[id] = append(oldIds[id], guid)
+	}
+	curIds := make(map[int][]int)
+	for guid, id := range cur {
+		curIds[id] = append(curIds[id], guid)
+	}
+	for id, oldGuids := range oldIds {
+		curGuids := curIds[id]
+		sort.Ints(oldGuids)
+		sort.Ints(curGuids)
+		for i := 0; i < len(oldGuids) && i < len(curGuids); i++ {
+			if oldGuids[i] != curGuids[i] {
+				guids[oldGuids[i]] = curGuids[i]
+			}
+		}
+	}
+}
+
+// 录制会话与当前会话的guid/actorId映射,按模板id分组后按guid顺序一一对应
+func (session *ReplaySession) mapping(account *Account) *guidRemap {
+	remap := &guidRemap{
+		guids:    make(map[int]int),
+		oldActor: float64(session.oldActorId),
+		newActor: float64(account.actorId),
+	}
+	pairGuids(remap.guids, heroIds(session.old), heroIds(account))
+	pairGuids(remap.guids, equipIds(session.old), equipIds(account))
+	pairGuids(remap.guids, artiIds(session.old), artiIds(account))
+	return remap
+}
+
+// 按msg.schema中的guid/actor字段映射,未描述或长度不足的消息原样发送,
+// 描述之外多出的字节原样附在后面
+func remapPayload(sysId, cmdId byte, payload []byte, remap *guidRemap) []byte {
+	newMsg, ok := clientMsgs[msgMark(sysId, cmdId)]
+	if !ok {
+		return payload
+	}
+	msg := newMsg()
+	reader := newMsgReader(bytes.NewReader(payload))
+	msg.Decode(reader)
+	if reader.Err() != nil {
+		return payload
+	}
+	msg.Remap(remap)
+	writer := &bytes.Buffer{}
+	msg.Encode(writer)
+	writer.Write(payload[len(payload)-reader.Len():])
+	return writer.Bytes()
+}
+
+func runReplay(account *Account, session *ReplaySession) {
+	log.Printf(account, "replay start: %d messages", len(session.records))
+	scheduleReplay(account, session, time.Now(), 0)
+}
+
+// 按录制时的间隔登记下一条消息的定时器,账号关闭时定时器随之停止
+func scheduleReplay(account *Account, session *ReplaySession, begin time.Time, index int) {
+	if index >= len(session.records) {
+		log.Printf(account, "replay finished")
+		stopClient(account.index)
+		return
+	}
+
+	speed := gConfigs.ReplaySpeed
+	if speed <= 0 {
+		speed = 1
+	}
+	delay := time.Duration(float64(session.records[index].Time-session.start)/speed) - time.Since(begin)
+	AfterDelay(account, "replay", delay, sendReplay, session, begin, index)
+}
+
+func sendReplay(account *Account, session *ReplaySession, begin time.Time, index int) {
+	if account.IsClose() {
+		log.Printf(account, "replay aborted")
+		return
+	}
+	record := session.records[index]
+	account.send(record.SysId, record.CmdId, remapPayload(record.SysId, record.CmdId, record.Payload, session.mapping(account)))
+	scheduleReplay(account, session, begin, index+1)
+}
+
+func startReplay(path string) error {
+	if err := loadReplay(path); err != nil {
+		return err
+	}
+
+	indexes := make([]int, 0, len(replaySessions))
+	for index := range replaySessions {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	gens := make([]int, len(indexes))
+	clientLock.Lock()
+	for i, index := range indexes {
+		wantClients[index] = true
+		clientGens[index]++
+		gens[i] = clientGens[index]
+	}
+	clientLock.Unlock()
+
+	for i, index := range indexes {
+		go startClient(index, gens[i])
+		time.Sleep(time.Millisecond * 50)
+	}
+	return nil
+}
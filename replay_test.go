@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sencydai/gameworld/proto/pack"
+	proto "github.com/sencydai/gameworld/proto/protocol"
+)
+
+func TestRemapPayloadOnlyGuidFields(t *testing.T) {
+	remap := &guidRemap{guids: map[int]int{1: 101, 2: 102}, oldActor: 1, newActor: 9}
+
+	//pos和wear与旧guid数值相同,不能被替换
+	data := remapPayload(proto.Hero, proto.HeroCWearEquip, pack.GetBytes(2, 1, 1), remap)
+	if !bytes.Equal(data, pack.GetBytes(2, 101, 1)) {
+		t.Fatalf("remap wear equip: %v", data)
+	}
+
+	//数量2与guid 2相同
+	data = remapPayload(proto.Hero, proto.HeroCHeroDismiss, pack.GetBytes(int16(2), 1, 2), remap)
+	if !bytes.Equal(data, pack.GetBytes(int16(2), 101, 102)) {
+		t.Fatalf("remap dismiss: %v", data)
+	}
+
+	data = remapPayload(proto.Lord, proto.LordCLookupHero, pack.GetBytes(0, "ab", 1, float64(1), 1), remap)
+	if !bytes.Equal(data, pack.GetBytes(0, "ab", 1, float64(9), 1)) {
+		t.Fatalf("remap lookup hero: %v", data)
+	}
+}
+
+func TestRemapPayloadKeepsUnknownAndShort(t *testing.T) {
+	remap := &guidRemap{guids: map[int]int{1: 101}}
+
+	payload := pack.GetBytes(1, 1)
+	if data := remapPayload(proto.Bag, proto.BagCOpenBox, payload, remap); !bytes.Equal(data, payload) {
+		t.Fatalf("unknown message changed: %v", data)
+	}
+
+	//数量超过实际guid个数
+	payload = pack.GetBytes(int16(3), 1)
+	if data := remapPayload(proto.Hero, proto.HeroCHeroDismiss, payload, remap); !bytes.Equal(data, payload) {
+		t.Fatalf("short message changed: %v", data)
+	}
+}
+
+func TestRemapPayloadKeepsTrailingBytes(t *testing.T) {
+	remap := &guidRemap{guids: map[int]int{1: 101}}
+
+	//转职复用升阶消息,guid之后多带职业
+	payload := pack.GetBytes(1, 1)
+	if data := remapPayload(proto.Hero, proto.HeroCUpgradeStage, payload, remap); !bytes.Equal(data, pack.GetBytes(101, 1)) {
+		t.Fatalf("remap change job: %v", data)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sencydai/gameworld/base"
+	proto "github.com/sencydai/gameworld/proto/protocol"
+)
+
+type CommandReport struct {
+	Name     string  `json:"name"`
+	Sent     uint64  `json:"sent"`
+	Recv     uint64  `json:"recv"`
+	SentRate float64 `json:"sentRate"`
+	RecvRate float64 `json:"recvRate"`
+}
+
+type RunReport struct {
+	Start         string                 `json:"start"`
+	End           string                 `json:"end"`
+	Duration      float64                `json:"duration"`
+	PeakOnline    int                    `json:"peakOnline"`
+	AvgOnline     float64                `json:"avgOnline"`
+	LoginAttempts uint64                 `json:"loginAttempts"`
+	LoginSuccess  uint64                 `json:"loginSuccess"`
+	LoginRate     float64                `json:"loginRate"`
+	Reconnects    uint64                 `json:"reconnects"`
+	IdleTimeouts  uint64                 `json:"idleTimeouts"`
+	DroppedPosts  uint64                 `json:"droppedPosts"`
+	HandlerPanics uint64                 `json:"handlerPanics"`
+	Commands      []*CommandReport       `json:"commands"`
+	Latency       []*LatencyStat         `json:"latency"`
+	ErrorCodes    map[string]map[int]int `json:"errorCodes"`
+	Integrity     map[string]uint64      `json:"integrity"`
+	Timing        []*LatencyStat         `json:"timing"`
+	Create        map[string]int         `json:"create"`
+	Churn         map[string]uint64      `json:"churn"`
+	Coverage      []*CoverageStat        `json:"coverage"`
+	Unhandled     []*UnhandledStat       `json:"unhandled"`
+	MsgErrors     []*MsgErrorStat        `json:"msgErrors"`
+	Expects       []*ExpectStat          `json:"expects"`
+}
+
+var (
+	runStart     = time.Now()
+	loginSuccess uint64
+
+	onlinePeak    int
+	onlineSum     int64
+	onlineSamples int64
+
+	//handler -> code -> count
+	errorCodes = make(map[string]map[int]int)
+	reportLock sync.Mutex
+)
+
+func RecordErrorCode(handler string, code int) {
+	reportLock.Lock()
+	defer reportLock.Unlock()
+
+	codes, ok := errorCodes[handler]
+	if !ok {
+		codes = make(map[int]int)
+		errorCodes[handler] = codes
+	}
+	codes[code]++
+}
+
+func countLoginSuccess() {
+	atomic.AddUint64(&loginSuccess, 1)
+}
+
+func onlineCount() int {
+	accountLock.RLock()
+	defer accountLock.RUnlock()
+
+	var count int
+	for _, account := range accounts {
+		if account.Status() == statusCommunication {
+			count++
+		}
+	}
+	return count
+}
+
+func sampleOnline() {
+	count := onlineCount()
+
+	reportLock.Lock()
+	defer reportLock.Unlock()
+
+	if count > onlinePeak {
+		onlinePeak = count
+	}
+	onlineSum += int64(count)
+	onlineSamples++
+}
+
+func commandReports(duration float64) []*CommandReport {
+	reports := make([]*CommandReport, 0)
+	for mark := range sentMsgs {
+		sent := atomic.LoadUint64(&sentMsgs[mark])
+		recv := atomic.LoadUint64(&recvMsgs[mark])
+		if sent == 0 && recv == 0 {
+			continue
+		}
+		reports = append(reports, &CommandReport{
+			Name:     markName(mark),
+			Sent:     sent,
+			Recv:     recv,
+			SentRate: float64(sent) / duration,
+			RecvRate: float64(recv) / duration,
+		})
+	}
+	return reports
+}
+
+func GetReport() *RunReport {
+	end := time.Now()
+	duration := end.Sub(runStart).Seconds()
+
+	report := &RunReport{
+		Start:         base.FormatDateTime(runStart),
+		End:           base.FormatDateTime(end),
+		Duration:      duration,
+		LoginAttempts: atomic.LoadUint64(&sentMsgs[msgMark(proto.System, proto.SystemCLogin)]),
+		LoginSuccess:  atomic.LoadUint64(&loginSuccess),
+		Reconnects:    atomic.LoadUint64(&reconnects),
+		IdleTimeouts:  IdleTimeouts(),
+		DroppedPosts:  DroppedPosts(),
+		HandlerPanics: atomic.LoadUint64(&handlerPanics),
+		Commands:      commandReports(duration),
+		Latency:       LatencySnapshot(),
+		ErrorCodes:    make(map[string]map[int]int),
+		Integrity:     IntegrityStats(),
+		Timing:        TimingSnapshot(),
+		Create:        CreateStats(),
+		Churn:         ChurnStats(),
+		Coverage:      CoverageSnapshot(),
+		Unhandled:     UnhandledSnapshot(),
+		MsgErrors:     MsgErrorSnapshot(),
+		Expects:       ExpectSnapshot(),
+	}
+	if report.LoginAttempts > 0 {
+		report.LoginRate = float64(report.LoginSuccess) / float64(report.LoginAttempts)
+	}
+
+	reportLock.Lock()
+	defer reportLock.Unlock()
+
+	report.PeakOnline = onlinePeak
+	if onlineSamples > 0 {
+		report.AvgOnline = float64(onlineSum) / float64(onlineSamples)
+	}
+	for handler, codes := range errorCodes {
+		report.ErrorCodes[handler] = make(map[int]int)
+		for code, count := range codes {
+			report.ErrorCodes[handler][code] = count
+		}
+	}
+	return report
+}
+
+func (report *RunReport) Markdown() []byte {
+	buff := &bytes.Buffer{}
+	fmt.Fprintf(buff, "# Robot Run Report\n\n")
+	fmt.Fprintf(buff, "| Item | Value |\n|---|---|\n")
+	fmt.Fprintf(buff, "| Start | %s |\n", report.Start)
+	fmt.Fprintf(buff, "| End | %s |\n", report.End)
+	fmt.Fprintf(buff, "| Duration | %.0fs |\n", report.Duration)
+	fmt.Fprintf(buff, "| Peak online | %d |\n", report.PeakOnline)
+	fmt.Fprintf(buff, "| Average online | %.1f |\n", report.AvgOnline)
+	fmt.Fprintf(buff, "| Login | %d/%d (%.2f%%) |\n", report.LoginSuccess, report.LoginAttempts, report.LoginRate*100)
+	fmt.Fprintf(buff, "| Reconnects | %d |\n", report.Reconnects)
+	fmt.Fprintf(buff, "| Idle timeouts | %d |\n", report.IdleTimeouts)
+	fmt.Fprintf(buff, "| Dropped posts | %d |\n", report.DroppedPosts)
+	fmt.Fprintf(buff, "| Handler panics | %d |\n", report.HandlerPanics)
+	for _, key := range sortedKeys(report.Integrity) {
+		fmt.Fprintf(buff, "| Frames %s | %d |\n", key, report.Integrity[key])
+	}
+
+	fmt.Fprintf(buff, "\n## Commands\n\n")
+	fmt.Fprintf(buff, "| Command | Sent | Recv | Sent/s | Recv/s |\n|---|---|---|---|---|\n")
+	for _, cmd := range report.Commands {
+		fmt.Fprintf(buff, "| %s | %d | %d | %.2f | %.2f |\n", cmd.Name, cmd.Sent, cmd.Recv, cmd.SentRate, cmd.RecvRate)
+	}
+
+	fmt.Fprintf(buff, "\n## Latency (ms)\n\n")
+	fmt.Fprintf(buff, "| Command | Count | Avg | P50 | P90 | P99 | Max |\n|---|---|---|---|---|---|---|\n")
+	for _, stat := range append(report.Latency, report.Timing...) {
+		fmt.Fprintf(buff, "| %s | %d | %.1f | %.1f | %.1f | %.1f | %.1f |\n",
+			stat.Name, stat.Count, stat.Avg, stat.P50, stat.P90, stat.P99, stat.Max)
+	}
+
+	fmt.Fprintf(buff, "\n## Actor Creation\n\n")
+	fmt.Fprintf(buff, "| Item | Count |\n|---|---|\n")
+	for _, key := range sortedKeys(report.Create) {
+		fmt.Fprintf(buff, "| %s | %d |\n", key, report.Create[key])
+	}
+
+	fmt.Fprintf(buff, "\n## Churn Events\n\n")
+	fmt.Fprintf(buff, "| Event | Count |\n|---|---|\n")
+	for _, key := range sortedKeys(report.Churn) {
+		fmt.Fprintf(buff, "| %s | %d |\n", key, report.Churn[key])
+	}
+
+	fmt.Fprintf(buff, "\n## Server Message Coverage\n\n")
+	writeCoverage(buff, report.Coverage, report.Unhandled)
+
+	fmt.Fprintf(buff, "\n## Expectations\n\n")
+	fmt.Fprintf(buff, "| Action | Pass | Fail | Timeout | Disconnect |\n|---|---|---|---|---|\n")
+	for _, stat := range report.Expects {
+		fmt.Fprintf(buff, "| %s | %d | %d | %d | %d |\n", stat.Action, stat.Pass, stat.Fail, stat.Timeout, stat.Disconnect)
+	}
+
+	fmt.Fprintf(buff, "\n## Message Errors\n\n")
+	fmt.Fprintf(buff, "| Message | Decode | Handle |\n|---|---|---|\n")
+	for _, stat := range report.MsgErrors {
+		fmt.Fprintf(buff, "| %s | %d | %d |\n", stat.Name, stat.Decode, stat.Handle)
+	}
+
+	fmt.Fprintf(buff, "\n## Error Codes\n\n")
+	fmt.Fprintf(buff, "| Handler | Code | Count |\n|---|---|---|\n")
+	handlers := make([]string, 0, len(report.ErrorCodes))
+	for handler := range report.ErrorCodes {
+		handlers = append(handlers, handler)
+	}
+	sort.Strings(handlers)
+	for _, handler := range handlers {
+		codes := make([]int, 0)
+		for code := range report.ErrorCodes[handler] {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			fmt.Fprintf(buff, "| %s | %d | %d |\n", handler, code, report.ErrorCodes[handler][code])
+		}
+	}
+	return buff.Bytes()
+}
+
+func writeReport(path string) {
+	report := GetReport()
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Print(nil, err.Error())
+		return
+	}
+	if err = ioutil.WriteFile(path+".json", data, 0644); err != nil {
+		log.Print(nil, err.Error())
+	}
+	if err = ioutil.WriteFile(path+".md", report.Markdown(), 0644); err != nil {
+		log.Print(nil, err.Error())
+	}
+	log.Printf(nil, "report written to %s.json/%s.md", path, path)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/sencydai/gameworld/base"
+)
+
+type ScenarioAction struct {
+	Name   string
+	Weight int
+	Times  int
+}
+
+type Scenario struct {
+	Name     string
+	Ratio    int
+	Sequence []*ScenarioAction
+	Common   []*ScenarioAction
+	Fight    []*ScenarioAction
+
+	commonWeight int
+	fightWeight  int
+}
+
+type ScenarioConfig struct {
+	Scenarios []*Scenario
+}
+
+var (
+	clientActions = make(map[string]ClientMsgHandler)
+	scenarios     = make([]*Scenario, 0)
+	scenarioRatio int
+)
+
+func actionName(handle ClientMsgHandler) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handle).Pointer()).Name()
+	if index := strings.LastIndex(name, "."); index >= 0 {
+		name = name[index+1:]
+	}
+	return name
+}
+
+func regClientAction(handle ClientMsgHandler) {
+	clientActions[actionName(handle)] = handle
+}
+
+func checkScenarioActions(scenario *Scenario, actions []*ScenarioAction) (int, error) {
+	var total int
+	for _, action := range actions {
+		if _, ok := clientActions[action.Name]; !ok {
+			return 0, fmt.Errorf("scenario %s: unknown action %s", scenario.Name, action.Name)
+		}
+		if action.Weight <= 0 {
+			action.Weight = 1
+		}
+		if action.Times <= 0 {
+			action.Times = 1
+		}
+		total += action.Weight
+	}
+	return total, nil
+}
+
+func loadScenarios(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	config := &ScenarioConfig{}
+	if err = json.Unmarshal(data, config); err != nil {
+		return err
+	}
+
+	for _, scenario := range config.Scenarios {
+		if scenario.Ratio <= 0 {
+			continue
+		}
+		if _, err = checkScenarioActions(scenario, scenario.Sequence); err != nil {
+			return err
+		}
+		if scenario.commonWeight, err = checkScenarioActions(scenario, scenario.Common); err != nil {
+			return err
+		}
+		if scenario.fightWeight, err = checkScenarioActions(scenario, scenario.Fight); err != nil {
+			return err
+		}
+		scenarios = append(scenarios, scenario)
+		scenarioRatio += scenario.Ratio
+	}
+	return nil
+}
+
+// 按账号序号分配场景,保证各场景账号比例稳定
+func pickScenario(index int) *Scenario {
+	if scenarioRatio == 0 {
+		return nil
+	}
+	slot := index % scenarioRatio
+	for _, scenario := range scenarios {
+		if slot < scenario.Ratio {
+			return scenario
+		}
+		slot -= scenario.Ratio
+	}
+	return nil
+}
+
+func pickAction(actions []*ScenarioAction, total int) ClientMsgHandler {
+	value := base.Rand(1, total)
+	for _, action := range actions {
+		if value <= action.Weight {
+			return clientActions[action.Name]
+		}
+		value -= action.Weight
+	}
+	return nil
+}
+
+// 执行场景序列的下一步,序列已结束返回false
+func nextSequenceAction(account *Account) bool {
+	scenario := account.scenario
+	if account.seqStep >= len(scenario.Sequence) {
+		return false
+	}
+
+	action := scenario.Sequence[account.seqStep]
+	account.seqCount++
+	if account.seqCount >= action.Times {
+		account.seqStep++
+		account.seqCount = 0
+	}
+	clientActions[action.Name](account)
+	return true
+}
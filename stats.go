@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	sentMsgs      [1 << 16]uint64
+	recvMsgs      [1 << 16]uint64
+	handlerPanics uint64
+	reconnects    uint64
+	dialing       int64
+
+	//在线账号 index -> account
+	accounts    = make(map[int]*Account)
+	accountLock sync.RWMutex
+)
+
+type StatsData struct {
+	Dialing       int64             `json:"dialing"`
+	Status        map[string]int    `json:"status"`
+	Sent          map[string]uint64 `json:"sent"`
+	Recv          map[string]uint64 `json:"recv"`
+	HandlerPanics uint64            `json:"handlerPanics"`
+	Reconnects    uint64            `json:"reconnects"`
+	IdleTimeouts  uint64            `json:"idleTimeouts"`
+	DroppedPosts  uint64            `json:"droppedPosts"`
+	Timers        int               `json:"timers"`
+	Latency       []*LatencyStat    `json:"latency"`
+	Integrity     map[string]uint64 `json:"integrity"`
+	Timing        []*LatencyStat    `json:"timing"`
+	LocalConns    map[string]int64  `json:"localConns"`
+	Create        map[string]int    `json:"create"`
+	Churn         map[string]uint64 `json:"churn"`
+	NetProfiles   map[string]int    `json:"netProfiles"`
+	Unhandled     []*UnhandledStat  `json:"unhandled"`
+	MsgErrors     []*MsgErrorStat   `json:"msgErrors"`
+	Expects       []*ExpectStat     `json:"expects"`
+}
+
+func (status connectStatus) String() string {
+	switch status {
+	case statusConnecting:
+		return "connecting"
+	case statusChecking:
+		return "checking"
+	case statusCommunication:
+		return "communication"
+	case statusDisconnect:
+		return "disconnect"
+	}
+	return fmt.Sprintf("status_%d", int(status))
+}
+
+func addAccount(account *Account) {
+	accountLock.Lock()
+	defer accountLock.Unlock()
+
+	accounts[account.index] = account
+}
+
+func removeAccount(account *Account) {
+	accountLock.Lock()
+	defer accountLock.Unlock()
+
+	if accounts[account.index] == account {
+		delete(accounts, account.index)
+	}
+}
+
+func countSent(sysId, cmdId byte) {
+	atomic.AddUint64(&sentMsgs[msgMark(sysId, cmdId)], 1)
+}
+
+func countRecv(sysId, cmdId byte) {
+	atomic.AddUint64(&recvMsgs[msgMark(sysId, cmdId)], 1)
+}
+
+func countPanic() {
+	atomic.AddUint64(&handlerPanics, 1)
+}
+
+func countReconnect() {
+	atomic.AddUint64(&reconnects, 1)
+}
+
+func statusCounts() map[string]int {
+	accountLock.RLock()
+	defer accountLock.RUnlock()
+
+	counts := make(map[string]int)
+	for _, account := range accounts {
+		counts[account.Status().String()]++
+	}
+	return counts
+}
+
+func netProfileCounts() map[string]int {
+	accountLock.RLock()
+	defer accountLock.RUnlock()
+
+	counts := make(map[string]int)
+	for _, account := range accounts {
+		if account.netProfile != nil {
+			counts[account.netProfile.Name]++
+		}
+	}
+	return counts
+}
+
+func systemCounts(counters *[1 << 16]uint64) map[string]uint64 {
+	counts := make(map[string]uint64)
+	for mark := range counters {
+		if count := atomic.LoadUint64(&counters[mark]); count > 0 {
+			counts[sysName(byte(mark>>8))] += count
+		}
+	}
+	return counts
+}
+
+func GetStats() *StatsData {
+	return &StatsData{
+		Dialing:       atomic.LoadInt64(&dialing),
+		Status:        statusCounts(),
+		Sent:          systemCounts(&sentMsgs),
+		Recv:          systemCounts(&recvMsgs),
+		HandlerPanics: atomic.LoadUint64(&handlerPanics),
+		Reconnects:    atomic.LoadUint64(&reconnects),
+		IdleTimeouts:  IdleTimeouts(),
+		DroppedPosts:  DroppedPosts(),
+		Timers:        TimerCount(),
+		Latency:       LatencySnapshot(),
+		Integrity:     IntegrityStats(),
+		Timing:        TimingSnapshot(),
+		LocalConns:    LocalConnCounts(),
+		Create:        CreateStats(),
+		Churn:         ChurnStats(),
+		NetProfiles:   netProfileCounts(),
+		Unhandled:     UnhandledSnapshot(),
+		MsgErrors:     MsgErrorSnapshot(),
+		Expects:       ExpectSnapshot(),
+	}
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := make([]string, 0)
+	switch v := m.(type) {
+	case map[string]int:
+		for key := range v {
+			keys = append(keys, key)
+		}
+	case map[string]uint64:
+		for key := range v {
+			keys = append(keys, key)
+		}
+	case map[string]int64:
+		for key := range v {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func handleStatsJson(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(GetStats())
+}
+
+func handleUnhandled(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(map[string]interface{}{
+		"coverage":  CoverageSnapshot(),
+		"unhandled": UnhandledSnapshot(),
+	})
+}
+
+func handleStatsMetrics(w http.ResponseWriter, r *http.Request) {
+	stats := GetStats()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# TYPE robot_accounts gauge")
+	fmt.Fprintf(w, "robot_accounts{status=\"connecting\"} %d\n", stats.Dialing)
+	for _, key := range sortedKeys(stats.Status) {
+		fmt.Fprintf(w, "robot_accounts{status=%q} %d\n", key, stats.Status[key])
+	}
+	fmt.Fprintln(w, "# TYPE robot_msgs_sent_total counter")
+	for _, key := range sortedKeys(stats.Sent) {
+		fmt.Fprintf(w, "robot_msgs_sent_total{sys=%q} %d\n", key, stats.Sent[key])
+	}
+	fmt.Fprintln(w, "# TYPE robot_msgs_recv_total counter")
+	for _, key := range sortedKeys(stats.Recv) {
+		fmt.Fprintf(w, "robot_msgs_recv_total{sys=%q} %d\n", key, stats.Recv[key])
+	}
+	fmt.Fprintln(w, "# TYPE robot_msgs_unhandled_total counter")
+	for _, stat := range stats.Unhandled {
+		fmt.Fprintf(w, "robot_msgs_unhandled_total{msg=%q} %d\n", stat.Name, stat.Count)
+	}
+	fmt.Fprintln(w, "# TYPE robot_msg_errors_total counter")
+	for _, stat := range stats.MsgErrors {
+		fmt.Fprintf(w, "robot_msg_errors_total{msg=%q,type=\"decode\"} %d\n", stat.Name, stat.Decode)
+		fmt.Fprintf(w, "robot_msg_errors_total{msg=%q,type=\"handle\"} %d\n", stat.Name, stat.Handle)
+	}
+	fmt.Fprintln(w, "# TYPE robot_expects_total counter")
+	for _, stat := range stats.Expects {
+		fmt.Fprintf(w, "robot_expects_total{action=%q,result=\"pass\"} %d\n", stat.Action, stat.Pass)
+		fmt.Fprintf(w, "robot_expects_total{action=%q,result=\"fail\"} %d\n", stat.Action, stat.Fail)
+		fmt.Fprintf(w, "robot_expects_total{action=%q,result=\"timeout\"} %d\n", stat.Action, stat.Timeout)
+		fmt.Fprintf(w, "robot_expects_total{action=%q,result=\"disconnect\"} %d\n", stat.Action, stat.Disconnect)
+	}
+	fmt.Fprintln(w, "# TYPE robot_net_profile_accounts gauge")
+	for _, key := range sortedKeys(stats.NetProfiles) {
+		fmt.Fprintf(w, "robot_net_profile_accounts{profile=%q} %d\n", key, stats.NetProfiles[key])
+	}
+	fmt.Fprintln(w, "# TYPE robot_local_conns gauge")
+	for _, key := range sortedKeys(stats.LocalConns) {
+		fmt.Fprintf(w, "robot_local_conns{ip=%q} %d\n", key, stats.LocalConns[key])
+	}
+	fmt.Fprintln(w, "# TYPE robot_create_total counter")
+	for _, key := range sortedKeys(stats.Create) {
+		fmt.Fprintf(w, "robot_create_total{type=%q} %d\n", key, stats.Create[key])
+	}
+	fmt.Fprintln(w, "# TYPE robot_churn_total counter")
+	for _, key := range sortedKeys(stats.Churn) {
+		fmt.Fprintf(w, "robot_churn_total{event=%q} %d\n", key, stats.Churn[key])
+	}
+	fmt.Fprintln(w, "# TYPE robot_handler_panics_total counter")
+	fmt.Fprintf(w, "robot_handler_panics_total %d\n", stats.HandlerPanics)
+	fmt.Fprintln(w, "# TYPE robot_reconnects_total counter")
+	fmt.Fprintf(w, "robot_reconnects_total %d\n", stats.Reconnects)
+	fmt.Fprintln(w, "# TYPE robot_idle_timeouts_total counter")
+	fmt.Fprintf(w, "robot_idle_timeouts_total %d\n", stats.IdleTimeouts)
+	fmt.Fprintln(w, "# TYPE robot_mailbox_dropped_total counter")
+	fmt.Fprintf(w, "robot_mailbox_dropped_total %d\n", stats.DroppedPosts)
+	fmt.Fprintln(w, "# TYPE robot_timers gauge")
+	fmt.Fprintf(w, "robot_timers %d\n", stats.Timers)
+	fmt.Fprintln(w, "# TYPE robot_frames_total counter")
+	for _, key := range sortedKeys(stats.Integrity) {
+		fmt.Fprintf(w, "robot_frames_total{check=%q} %d\n", key, stats.Integrity[key])
+	}
+	fmt.Fprintln(w, "# TYPE robot_latency_ms summary")
+	for _, stat := range stats.Latency {
+		fmt.Fprintf(w, "robot_latency_ms{cmd=%q,quantile=\"0.5\"} %.3f\n", stat.Name, stat.P50)
+		fmt.Fprintf(w, "robot_latency_ms{cmd=%q,quantile=\"0.9\"} %.3f\n", stat.Name, stat.P90)
+		fmt.Fprintf(w, "robot_latency_ms{cmd=%q,quantile=\"0.99\"} %.3f\n", stat.Name, stat.P99)
+		fmt.Fprintf(w, "robot_latency_ms_sum{cmd=%q} %.3f\n", stat.Name, stat.Avg*float64(stat.Count))
+		fmt.Fprintf(w, "robot_latency_ms_count{cmd=%q} %d\n", stat.Name, stat.Count)
+	}
+	fmt.Fprintln(w, "# TYPE robot_timing_ms summary")
+	for _, stat := range stats.Timing {
+		fmt.Fprintf(w, "robot_timing_ms{name=%q,quantile=\"0.5\"} %.3f\n", stat.Name, stat.P50)
+		fmt.Fprintf(w, "robot_timing_ms{name=%q,quantile=\"0.9\"} %.3f\n", stat.Name, stat.P90)
+		fmt.Fprintf(w, "robot_timing_ms{name=%q,quantile=\"0.99\"} %.3f\n", stat.Name, stat.P99)
+		fmt.Fprintf(w, "robot_timing_ms_count{name=%q} %d\n", stat.Name, stat.Count)
+	}
+}
+
+// host为空时只监听本机
+func startStatsServer(host string, port int) {
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", handleStatsJson)
+	mux.HandleFunc("/metrics", handleStatsMetrics)
+	mux.HandleFunc("/unhandled", handleUnhandled)
+
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf(nil, "stats server error: %s", err.Error())
+		}
+	}()
+}
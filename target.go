@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+)
+
+type Target struct {
+	Scheme   string
+	Host     string
+	ServerId int
+	Weight   int
+}
+
+var (
+	targets      = make([]*Target, 0)
+	targetWeight int
+
+	//actorId -> serverId
+	serverActors   = make(map[int64]int)
+	actorsByServer = make(map[int][]int64)
+	serverLock     sync.RWMutex
+)
+
+func initTargets() {
+	list := gConfigs.Targets
+	if len(list) == 0 {
+		list = []*Target{{Host: gConfigs.Host, ServerId: gConfigs.ServerId}}
+	}
+	for _, target := range list {
+		if target.Scheme == "" {
+			target.Scheme = gConfigs.Scheme
+		}
+		if target.Weight <= 0 {
+			target.Weight = 1
+		}
+		targets = append(targets, target)
+		targetWeight += target.Weight
+	}
+}
+
+// 按客户端序号及权重分配目标服务器
+func targetFor(i int) *Target {
+	slot := i % targetWeight
+	for _, target := range targets {
+		if slot < target.Weight {
+			return target
+		}
+		slot -= target.Weight
+	}
+	return targets[0]
+}
+
+func addServerActor(actorId int64, serverId int) {
+	serverLock.Lock()
+	defer serverLock.Unlock()
+
+	if _, ok := serverActors[actorId]; ok {
+		return
+	}
+	serverActors[actorId] = serverId
+	actorsByServer[serverId] = append(actorsByServer[serverId], actorId)
+}
+
+// 随机选择一个角色,优先选择其他服务器上的角色
+func randomServerActor(serverId int) (int64, int, bool) {
+	serverLock.RLock()
+	defer serverLock.RUnlock()
+
+	others := make([]int, 0, len(actorsByServer))
+	for id := range actorsByServer {
+		if id != serverId {
+			others = append(others, id)
+		}
+	}
+	if len(others) > 0 {
+		serverId = others[rand.Intn(len(others))]
+	}
+	actors := actorsByServer[serverId]
+	if len(actors) == 0 {
+		return 0, 0, false
+	}
+	return actors[rand.Intn(len(actors))], serverId, true
+}
@@ -9,54 +9,72 @@ import (
 	"github.com/sencydai/gameworld/base"
 )
 
+// 定时器记录,到期时只执行仍登记中的记录,停止或同名替换后不再回调
+type timerEntry struct {
+	timer *time.Timer
+}
+
 var (
-	sysTimers     = make(map[string]*time.Timer)
-	accountTimers = make(map[*Account]map[string]*time.Timer)
+	sysTimers     = make(map[string]*timerEntry)
+	accountTimers = make(map[*Account]map[string]*timerEntry)
 	timerLock     sync.RWMutex
 )
 
-func addTimer(account *Account, name string, delay int) *time.Timer {
+// 调用方持有timerLock
+func timersOf(account *Account, create bool) map[string]*timerEntry {
+	if account == nil {
+		return sysTimers
+	}
+	timers, ok := accountTimers[account]
+	if !ok && create {
+		timers = make(map[string]*timerEntry)
+		accountTimers[account] = timers
+	}
+	return timers
+}
+
+func addTimer(account *Account, name string, entry *timerEntry, delay time.Duration, fire func()) {
 	timerLock.Lock()
 	defer timerLock.Unlock()
 
-	sec := time.Second * time.Duration(delay)
-
-	if account == nil {
-		if t, ok := sysTimers[name]; ok {
-			t.Stop()
-		}
-		t := time.NewTimer(sec)
-		sysTimers[name] = t
-		return t
+	timers := timersOf(account, true)
+	if old, ok := timers[name]; ok {
+		old.timer.Stop()
 	}
+	entry.timer = time.AfterFunc(delay, fire)
+	timers[name] = entry
+}
 
-	accounts, ok := accountTimers[account]
-	if !ok {
-		accounts = make(map[string]*time.Timer)
-		accountTimers[account] = accounts
-	} else if t, ok := accounts[name]; ok {
-		t.Stop()
+// entry仍登记中时重新计时
+func resetTimer(account *Account, name string, entry *timerEntry, delay time.Duration, fire func()) bool {
+	timerLock.Lock()
+	defer timerLock.Unlock()
+
+	if timersOf(account, false)[name] != entry {
+		return false
 	}
+	entry.timer = time.AfterFunc(delay, fire)
+	return true
+}
+
+// entry仍登记中时移除
+func removeTimer(account *Account, name string, entry *timerEntry) bool {
+	timerLock.Lock()
+	defer timerLock.Unlock()
 
-	t := time.NewTimer(sec)
-	accounts[name] = t
-	return t
+	timers := timersOf(account, false)
+	if timers[name] != entry {
+		return false
+	}
+	delete(timers, name)
+	return true
 }
 
 func IsStoped(account *Account, name string) bool {
 	timerLock.RLock()
 	defer timerLock.RUnlock()
 
-	if account == nil {
-		_, ok := sysTimers[name]
-		return !ok
-	}
-
-	accounts, ok := accountTimers[account]
-	if !ok {
-		return true
-	}
-	_, ok = accounts[name]
+	_, ok := timersOf(account, false)[name]
 	return !ok
 }
 
@@ -64,26 +82,13 @@ func StopTimer(account *Account, name string) bool {
 	timerLock.Lock()
 	defer timerLock.Unlock()
 
-	if account == nil {
-		t, ok := sysTimers[name]
-		if !ok {
-			return false
-		}
-		t.Stop()
-		delete(sysTimers, name)
-		return true
-	}
-
-	accounts, ok := accountTimers[account]
+	timers := timersOf(account, false)
+	entry, ok := timers[name]
 	if !ok {
 		return false
 	}
-	t, ok := accounts[name]
-	if !ok {
-		return false
-	}
-	t.Stop()
-	delete(accounts, name)
+	entry.timer.Stop()
+	delete(timers, name)
 	return true
 }
 
@@ -91,20 +96,43 @@ func StopAccountTimers(account *Account) {
 	timerLock.Lock()
 	defer timerLock.Unlock()
 
-	accounts, ok := accountTimers[account]
+	timers, ok := accountTimers[account]
 	if !ok {
 		return
 	}
-	for _, t := range accounts {
-		t.Stop()
+	for _, entry := range timers {
+		entry.timer.Stop()
 	}
 	delete(accountTimers, account)
 }
 
+func TimerCount() int {
+	timerLock.RLock()
+	defer timerLock.RUnlock()
+
+	count := len(sysTimers)
+	for _, timers := range accountTimers {
+		count += len(timers)
+	}
+	return count
+}
+
+// 账号定时器回调投递到账号goroutine执行
 func callback(account *Account, cbFunc interface{}, args []interface{}) {
+	if account != nil && account.mailbox != nil {
+		account.post(func() {
+			invoke(account, cbFunc, args)
+		})
+		return
+	}
+	invoke(account, cbFunc, args)
+}
+
+func invoke(account *Account, cbFunc interface{}, args []interface{}) {
 	defer func() {
 		if err := recover(); err != nil {
 			log.Printf(account, "%v: %s", err, string(debug.Stack()))
+			countPanic()
 		}
 	}()
 
@@ -121,42 +149,38 @@ func callback(account *Account, cbFunc interface{}, args []interface{}) {
 }
 
 func After(account *Account, name string, delay int, cbFunc interface{}, args ...interface{}) {
-	go func() {
-		t := addTimer(account, name, delay)
-		select {
-		case <-t.C:
-			StopTimer(account, name)
+	AfterDelay(account, name, time.Second*time.Duration(delay), cbFunc, args...)
+}
+
+func AfterDelay(account *Account, name string, delay time.Duration, cbFunc interface{}, args ...interface{}) {
+	entry := &timerEntry{}
+	addTimer(account, name, entry, delay, func() {
+		if removeTimer(account, name, entry) {
 			callback(account, cbFunc, args)
 		}
-	}()
+	})
 }
 
+// times<=0时无限循环
 func Loop(account *Account, name string, delay, interval, times int, cbFunc interface{}, args ...interface{}) {
-	go func() {
-		loop := time.Second * time.Duration(interval)
-		t := addTimer(account, name, delay)
-
-		var count int
-		for !IsStoped(account, name) {
-			select {
-			case <-t.C:
-				if times > 0 {
-					if count < times {
-						count++
-						if count == times {
-							StopTimer(account, name)
-							callback(account, cbFunc, args)
-							return
-						}
-
-						t.Reset(loop)
-						callback(account, cbFunc, args)
-					}
-				} else {
-					t.Reset(loop)
+	loop := time.Second * time.Duration(interval)
+	entry := &timerEntry{}
+	//每次到期后才登记下一次,count不会并发访问
+	var count int
+	var fire func()
+	fire = func() {
+		if times > 0 {
+			count++
+			if count >= times {
+				if removeTimer(account, name, entry) {
 					callback(account, cbFunc, args)
 				}
+				return
 			}
 		}
-	}()
+		if resetTimer(account, name, entry, loop, fire) {
+			callback(account, cbFunc, args)
+		}
+	}
+	addTimer(account, name, entry, time.Second*time.Duration(delay), fire)
 }
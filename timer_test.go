@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStopTimerNoGoroutineLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		AfterDelay(nil, fmt.Sprintf("testLeak_%d", i), time.Hour, func() {})
+		Loop(nil, fmt.Sprintf("testLeakLoop_%d", i), 3600, 3600, -1, func() {})
+	}
+	for i := 0; i < 100; i++ {
+		StopTimer(nil, fmt.Sprintf("testLeak_%d", i))
+		StopTimer(nil, fmt.Sprintf("testLeakLoop_%d", i))
+	}
+	if after := runtime.NumGoroutine(); after > before+10 {
+		t.Fatalf("goroutines %d -> %d", before, after)
+	}
+}
+
+func TestAfterDelayReplaceAndStop(t *testing.T) {
+	var first, second, stopped int32
+	AfterDelay(nil, "testReplace", time.Millisecond*20, func() { atomic.AddInt32(&first, 1) })
+	AfterDelay(nil, "testReplace", time.Millisecond*20, func() { atomic.AddInt32(&second, 1) })
+	AfterDelay(nil, "testStop", time.Millisecond*20, func() { atomic.AddInt32(&stopped, 1) })
+	if !StopTimer(nil, "testStop") {
+		t.Fatal("stop timer")
+	}
+
+	time.Sleep(time.Millisecond * 100)
+	if atomic.LoadInt32(&first) != 0 || atomic.LoadInt32(&second) != 1 || atomic.LoadInt32(&stopped) != 0 {
+		t.Fatalf("first %d second %d stopped %d", first, second, stopped)
+	}
+	if !IsStoped(nil, "testReplace") {
+		t.Fatal("fired timer still registered")
+	}
+}
+
+func TestLoopTimes(t *testing.T) {
+	var count int32
+	Loop(nil, "testLoopTimes", 0, 0, 3, func(n int) { atomic.AddInt32(&count, int32(n)) }, 1)
+	time.Sleep(time.Millisecond * 100)
+	if c := atomic.LoadInt32(&count); c != 3 {
+		t.Fatalf("loop count %d", c)
+	}
+	if !IsStoped(nil, "testLoopTimes") {
+		t.Fatal("loop still registered")
+	}
+}
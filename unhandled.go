@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+type unhandledMsg struct {
+	count   uint64
+	bytes   uint64
+	minSize int
+	maxSize int
+}
+
+type UnhandledStat struct {
+	Name    string  `json:"name"`
+	SysId   byte    `json:"sysId"`
+	CmdId   byte    `json:"cmdId"`
+	Count   uint64  `json:"count"`
+	AvgSize float64 `json:"avgSize"`
+	MinSize int     `json:"minSize"`
+	MaxSize int     `json:"maxSize"`
+}
+
+// 按系统统计的处理覆盖情况
+type CoverageStat struct {
+	System    string  `json:"system"`
+	Handled   uint64  `json:"handled"`
+	Unhandled uint64  `json:"unhandled"`
+	Commands  int     `json:"commands"`
+	Coverage  float64 `json:"coverage"`
+}
+
+var (
+	unhandledMsgs = make(map[int]*unhandledMsg)
+	unhandledLock sync.Mutex
+)
+
+// 记录没有注册处理函数的服务器消息,size为包体长度(不含sysId/cmdId)
+func recordUnhandled(sysId, cmdId byte, size int) {
+	unhandledLock.Lock()
+	defer unhandledLock.Unlock()
+
+	mark := msgMark(sysId, cmdId)
+	msg, ok := unhandledMsgs[mark]
+	if !ok {
+		msg = &unhandledMsg{minSize: size, maxSize: size}
+		unhandledMsgs[mark] = msg
+	}
+	msg.count++
+	msg.bytes += uint64(size)
+	if size < msg.minSize {
+		msg.minSize = size
+	}
+	if size > msg.maxSize {
+		msg.maxSize = size
+	}
+}
+
+// 按数量从多到少排序
+func UnhandledSnapshot() []*UnhandledStat {
+	unhandledLock.Lock()
+	defer unhandledLock.Unlock()
+
+	stats := make([]*UnhandledStat, 0, len(unhandledMsgs))
+	for mark, msg := range unhandledMsgs {
+		stats = append(stats, &UnhandledStat{
+			Name:    markName(mark),
+			SysId:   byte(mark >> 8),
+			CmdId:   byte(mark),
+			Count:   msg.count,
+			AvgSize: float64(msg.bytes) / float64(msg.count),
+			MinSize: msg.minSize,
+			MaxSize: msg.maxSize,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+func CoverageSnapshot() []*CoverageStat {
+	unhandled := UnhandledSnapshot()
+	systems := make(map[byte]*CoverageStat)
+	getSystem := func(sysId byte) *CoverageStat {
+		stat, ok := systems[sysId]
+		if !ok {
+			stat = &CoverageStat{System: sysName(sysId)}
+			systems[sysId] = stat
+		}
+		return stat
+	}
+	for _, msg := range unhandled {
+		stat := getSystem(msg.SysId)
+		stat.Unhandled += msg.Count
+		stat.Commands++
+	}
+	for mark := range recvMsgs {
+		if _, ok := serverMsgHandles[mark]; !ok {
+			continue
+		}
+		if count := atomic.LoadUint64(&recvMsgs[mark]); count > 0 {
+			getSystem(byte(mark >> 8)).Handled += count
+		}
+	}
+
+	stats := make([]*CoverageStat, 0, len(systems))
+	for _, stat := range systems {
+		if total := stat.Handled + stat.Unhandled; total > 0 {
+			stat.Coverage = float64(stat.Handled) / float64(total)
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].System < stats[j].System
+	})
+	return stats
+}
+
+func writeCoverage(buff *bytes.Buffer, coverage []*CoverageStat, unhandled []*UnhandledStat) {
+	fmt.Fprintf(buff, "| System | Handled | Unhandled | Unhandled cmds | Coverage |\n|---|---|---|---|---|\n")
+	for _, stat := range coverage {
+		fmt.Fprintf(buff, "| %s | %d | %d | %d | %.2f%% |\n",
+			stat.System, stat.Handled, stat.Unhandled, stat.Commands, stat.Coverage*100)
+	}
+	fmt.Fprintf(buff, "\n| Unhandled message | Count | Avg size | Min size | Max size |\n|---|---|---|---|---|\n")
+	for _, stat := range unhandled {
+		fmt.Fprintf(buff, "| %s | %d | %.1f | %d | %d |\n",
+			stat.Name, stat.Count, stat.AvgSize, stat.MinSize, stat.MaxSize)
+	}
+}
+
+func printUnhandled() {
+	buff := &bytes.Buffer{}
+	writeCoverage(buff, CoverageSnapshot(), UnhandledSnapshot())
+	log.Printf(nil, "server message coverage:\n%s", buff.String())
+}